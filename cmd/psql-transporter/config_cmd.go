@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/jayps/psql-transporter/internal/config"
+)
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect and maintain the config file",
+	}
+	cmd.AddCommand(newConfigMigrateCmd(), newConfigShowCmd(), newConfigEncryptCmd(), newConfigDecryptCmd())
+	return cmd
+}
+
+func newConfigShowCmd() *cobra.Command {
+	var resolved bool
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the config files (secrets redacted)",
+		Long: "Print each config file in precedence order, lowest first. With --resolved,\n" +
+			"print the merged result that a transfer would actually use.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfgPath := filepath.Join(".", config.DefaultFile)
+			if resolved {
+				c, err := config.LoadRaw(cfgPath)
+				if err != nil {
+					return err
+				}
+				return printYAML(c.Redacted())
+			}
+			layers, err := config.Layers(cfgPath)
+			if err != nil {
+				return err
+			}
+			for i, layer := range layers {
+				b, err := config.RedactedFile(layer)
+				if err != nil {
+					return err
+				}
+				if i > 0 {
+					fmt.Println()
+				}
+				fmt.Println(pterm.Bold.Sprint("# " + layer))
+				fmt.Print(string(b))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&resolved, "resolved", false, "Print the merged config instead of each file")
+	return cmd
+}
+
+func newConfigEncryptCmd() *cobra.Command {
+	var file string
+	cmd := &cobra.Command{
+		Use:   "encrypt",
+		Short: "Encrypt plaintext passwords in a config file for the configured age recipients",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfgPath := filepath.Join(".", config.DefaultFile)
+			if file == "" {
+				file = cfgPath
+			}
+			c, err := config.LoadRaw(cfgPath)
+			if err != nil {
+				return err
+			}
+			n, err := config.EncryptFile(file, c.Encryption.Recipients)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Encrypted %d password(s) in %s\n", n, file)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&file, "file", "", "Config file to rewrite (default: the shared config file)")
+	return cmd
+}
+
+func newConfigDecryptCmd() *cobra.Command {
+	var file string
+	cmd := &cobra.Command{
+		Use:   "decrypt",
+		Short: "Turn encrypted passwords in a config file back into plaintext",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfgPath := filepath.Join(".", config.DefaultFile)
+			if file == "" {
+				file = cfgPath
+			}
+			c, err := config.LoadRaw(cfgPath)
+			if err != nil {
+				return err
+			}
+			identity, err := config.IdentityPath(c)
+			if err != nil {
+				return err
+			}
+			n, err := config.DecryptFile(file, identity)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Decrypted %d password(s) in %s\n", n, file)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&file, "file", "", "Config file to rewrite (default: the shared config file)")
+	return cmd
+}
+
+func printYAML(v any) error {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(b))
+	return nil
+}
+
+func newConfigMigrateCmd() *cobra.Command {
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Upgrade the config file to the current format version",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfgPath := filepath.Join(".", config.DefaultFile)
+			res, err := config.Migrate(cfgPath, dryRun)
+			if err != nil {
+				return err
+			}
+			if !res.Changed() {
+				fmt.Printf("%s is already at version %d\n", cfgPath, res.To)
+				return nil
+			}
+			if dryRun {
+				fmt.Printf("%s would be migrated from version %d to %d:\n\n", cfgPath, res.From, res.To)
+				printDiff(res.Diff())
+				return nil
+			}
+			fmt.Printf("Migrated %s from version %d to %d (backup at %s)\n", cfgPath, res.From, res.To, res.Backup)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the changes without writing them")
+	return cmd
+}
+
+// printDiff prints a line diff with added lines in green and removed lines in red.
+func printDiff(diff string) {
+	for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+"):
+			fmt.Println(pterm.FgGreen.Sprint(line))
+		case strings.HasPrefix(line, "-"):
+			fmt.Println(pterm.FgRed.Sprint(line))
+		default:
+			fmt.Println(line)
+		}
+	}
+}
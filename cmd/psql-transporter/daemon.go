@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+
+	"github.com/jayps/psql-transporter/internal/config"
+	"github.com/jayps/psql-transporter/internal/ui"
+)
+
+func newDaemonCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "daemon",
+		Short: "Run the configured schedules unattended until stopped",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := loadConfig(filepath.Join(".", config.DefaultFile))
+			if err != nil {
+				return err
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return runDaemon(ctx, c)
+		},
+	}
+}
+
+// daemon runs schedules one at a time; see fire.
+type daemon struct {
+	cfg config.Config
+
+	mu      sync.Mutex
+	current string // schedule being run, if any
+}
+
+// runDaemon runs every schedule of c whenever it comes due, until ctx is done. It
+// then waits for the run in progress, which ctx cancels, to wind down.
+func runDaemon(ctx context.Context, c config.Config) error {
+	if len(c.Schedules) == 0 {
+		return errors.New("no schedules in the config")
+	}
+	d := &daemon{cfg: c}
+	cr := cron.New(cron.WithChain(cron.Recover(cron.DefaultLogger)))
+	scheds := make([]cron.Schedule, len(c.Schedules))
+	for i, s := range c.Schedules {
+		switch {
+		case s.Name == "":
+			return fmt.Errorf("schedule %d needs a name", i+1)
+		case slices.ContainsFunc(c.Schedules[:i], func(o config.Schedule) bool { return o.Name == s.Name }):
+			return fmt.Errorf("schedule %q is listed twice", s.Name)
+		}
+		if _, err := s.Run(c); err != nil {
+			return err
+		}
+		sched, err := cron.ParseStandard(s.Cron)
+		if err != nil {
+			return fmt.Errorf("schedule %q: cron %q: %w", s.Name, s.Cron, err)
+		}
+		scheds[i] = sched
+		cr.Schedule(sched, cron.FuncJob(func() { d.fire(ctx, s) }))
+	}
+
+	ui.Plain()
+	st, err := config.LoadState(c.StatePath())
+	if err != nil {
+		pterm.Warning.Printfln("Could not read the last runs: %v", err)
+	}
+	now := time.Now()
+	rows := make([][]string, len(c.Schedules))
+	for i, s := range c.Schedules {
+		last := "never"
+		if r, ok := st.Schedules[s.Name]; ok {
+			last = fmt.Sprintf("%s %s", r.Status, r.Started.Local().Format(time.DateTime))
+		}
+		rows[i] = []string{s.Name, s.Cron, scheduleTarget(c, s), scheds[i].Next(now).Format(time.DateTime), last}
+	}
+	if err := ui.Table([]string{"Schedule", "Cron", "Runs", "Next run", "Last run"}, rows); err != nil {
+		return err
+	}
+
+	cr.Start()
+	logf("Daemon started with %d schedule(s); stop it with SIGTERM or Ctrl-C.", len(c.Schedules))
+	<-ctx.Done()
+	d.mu.Lock()
+	current := d.current
+	d.mu.Unlock()
+	if current != "" {
+		logf("Stopping; cancelling %s and waiting for it to clean up...", current)
+	}
+	<-cr.Stop().Done()
+	logf("Daemon stopped.")
+	return nil
+}
+
+// scheduleTarget describes what s runs.
+func scheduleTarget(c config.Config, s config.Schedule) string {
+	if s.Recipe != "" {
+		return "recipe " + s.Recipe
+	}
+	r, _ := s.Run(c)
+	return fmt.Sprintf("%s → %s", recipeSource(r), strings.Join(r.To, ", "))
+}
+
+// fire runs s unattended and records the outcome in the state file. Runs never
+// overlap: if another schedule's run, or the previous run of s, is still going on,
+// s is skipped until it next comes due.
+func (d *daemon) fire(ctx context.Context, s config.Schedule) {
+	if ctx.Err() != nil {
+		return
+	}
+	d.mu.Lock()
+	busy := d.current
+	if busy == "" {
+		d.current = s.Name
+	}
+	d.mu.Unlock()
+	if busy != "" {
+		if busy == s.Name {
+			logf("%s: skipped; its previous run is still going on", s.Name)
+		} else {
+			logf("%s: skipped; %s is still running", s.Name, busy)
+		}
+		d.record(s.Name, func(r *config.ScheduleRun) { r.Skipped = time.Now() })
+		return
+	}
+	defer func() {
+		d.mu.Lock()
+		d.current = ""
+		d.mu.Unlock()
+	}()
+
+	start := time.Now()
+	logf("%s: starting", s.Name)
+	d.record(s.Name, func(r *config.ScheduleRun) {
+		*r = config.ScheduleRun{Started: start, Status: config.RunRunning, Skipped: r.Skipped}
+	})
+	err := d.run(ctx, s)
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("interrupted by shutdown: %w", err)
+	}
+	took := time.Since(start).Round(time.Second)
+	d.record(s.Name, func(r *config.ScheduleRun) {
+		r.Finished = time.Now()
+		r.Status = config.RunSucceeded
+		if err != nil {
+			r.Status, r.Error = config.RunFailed, err.Error()
+		}
+	})
+	if err != nil {
+		logf("%s: failed after %s: %v", s.Name, took, err)
+		return
+	}
+	logf("%s: succeeded in %s", s.Name, took)
+}
+
+// run runs the transfer s describes, with the same policy checks and destination
+// locks as an interactive run, but without asking anything.
+func (d *daemon) run(ctx context.Context, s config.Schedule) error {
+	r, err := s.Run(d.cfg)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	o := recipeOptions(r)
+	o.unattended = true
+	return runTransfer(ctx, r.Apply(d.cfg), o)
+}
+
+// record updates the last run of the schedule called name in the state file. A state
+// file that can't be read or written is reported, but doesn't stop the daemon.
+func (d *daemon) record(name string, update func(*config.ScheduleRun)) {
+	err := config.UpdateState(d.cfg.StatePath(), func(st *config.State) {
+		if st.Schedules == nil {
+			st.Schedules = make(map[string]config.ScheduleRun)
+		}
+		r := st.Schedules[name]
+		update(&r)
+		st.Schedules[name] = r
+	})
+	if err != nil {
+		pterm.Warning.Printfln("Could not record the run of %s: %v", name, err)
+	}
+}
+
+// logf prints a line of the daemon's own log, with the time in front.
+func logf(format string, args ...any) {
+	fmt.Printf("%s %s\n", time.Now().Format(time.DateTime), fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jayps/psql-transporter/internal/config"
+)
+
+func TestDaemonFire(t *testing.T) {
+	started := time.Date(2026, 10, 1, 2, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		current    string
+		schedule   config.Schedule
+		wantStatus string
+		wantSkip   bool
+		wantError  string
+	}{
+		{
+			name:       "another schedule is running",
+			current:    "hourly",
+			schedule:   config.Schedule{Name: "nightly", Recipe: "refresh-dev"},
+			wantStatus: config.RunSucceeded,
+			wantSkip:   true,
+		},
+		{
+			name:       "its previous run is still going on",
+			current:    "nightly",
+			schedule:   config.Schedule{Name: "nightly", Recipe: "refresh-dev"},
+			wantStatus: config.RunSucceeded,
+			wantSkip:   true,
+		},
+		{
+			name:       "run fails",
+			schedule:   config.Schedule{Name: "nightly", Recipe: "missing"},
+			wantStatus: config.RunFailed,
+			wantError:  `schedule "nightly": no recipe named "missing" (want one of refresh-dev, broken)`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state.json")
+			err := config.SaveState(path, config.State{
+				Watermarks: map[string]map[string]string{"staging -> dev": {"orders": "42"}},
+				Schedules:  map[string]config.ScheduleRun{"nightly": {Started: started, Status: config.RunSucceeded}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := testConfig()
+			c.StateFile = path
+			d := &daemon{cfg: c, current: tt.current}
+			d.fire(context.Background(), tt.schedule)
+
+			if d.current != tt.current {
+				t.Errorf("current = %q, want %q", d.current, tt.current)
+			}
+			st, err := config.LoadState(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := st.Schedules["nightly"]
+			if r.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", r.Status, tt.wantStatus)
+			}
+			if r.Skipped.IsZero() == tt.wantSkip {
+				t.Errorf("skipped = %v, want skipped %v", r.Skipped, tt.wantSkip)
+			}
+			if tt.wantSkip && !r.Started.Equal(started) {
+				t.Errorf("started = %v, want the previous run's %v kept", r.Started, started)
+			}
+			if r.Error != tt.wantError {
+				t.Errorf("error = %q, want %q", r.Error, tt.wantError)
+			}
+			if got := st.Watermarks["staging -> dev"]["orders"]; got != "42" {
+				t.Errorf("watermark = %q, want 42 kept", got)
+			}
+		})
+	}
+}
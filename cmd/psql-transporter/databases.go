@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+
+	"github.com/jayps/psql-transporter/internal/config"
+	"github.com/jayps/psql-transporter/internal/psql"
+	"github.com/jayps/psql-transporter/internal/ui"
+)
+
+// copyDatabases refreshes databases of the server-level source src into the
+// server-level destination dst, one full transfer per database, creating the ones dst
+// lacks. o.databases selects the databases; empty means every database except the
+// maintenance database. A failing database doesn't stop the others, and one report
+// covers them all.
+func copyDatabases(ctx context.Context, c config.Config, src, dst config.Source, o transferOptions) error {
+	type servers struct{ src, dst []psql.DatabaseInfo }
+	dbs, err := ui.StepSpinner("Listing databases...", func() (servers, error) {
+		var s servers
+		var err error
+		if s.src, err = psql.Databases(ctx, toConn(src)); err != nil {
+			return s, fmt.Errorf("source %q: %w", src.Name, err)
+		}
+		if s.dst, err = psql.Databases(ctx, toConn(dst)); err != nil {
+			return s, fmt.Errorf("destination %q: %w", dst.Name, err)
+		}
+		return s, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var selected []psql.DatabaseInfo
+	if len(o.databases) == 0 {
+		for _, d := range dbs.src {
+			if d.Name != src.MaintenanceDB() && d.Name != config.DefaultMaintenanceDB {
+				selected = append(selected, d)
+			}
+		}
+	} else {
+		for _, name := range o.databases {
+			i := slices.IndexFunc(dbs.src, func(d psql.DatabaseInfo) bool { return d.Name == name })
+			if i < 0 {
+				return fmt.Errorf("source %q has no database %q", src.Name, name)
+			}
+			selected = append(selected, dbs.src[i])
+		}
+	}
+	if len(selected) == 0 {
+		return fmt.Errorf("source %q has no databases to copy", src.Name)
+	}
+
+	existing := make(map[string]int64, len(dbs.dst))
+	for _, d := range dbs.dst {
+		existing[d.Name] = d.Bytes
+	}
+	created := 0
+	rows := make([][]string, len(selected))
+	for i, d := range selected {
+		status := pterm.FgGreen.Sprint("created")
+		if size, ok := existing[d.Name]; ok {
+			status = fmt.Sprintf("replaced (%s)", humanSize(size))
+		} else {
+			created++
+		}
+		rows[i] = []string{d.Name, humanSize(d.Bytes), status}
+	}
+	fmt.Printf("Databases from %q to DESTINATION %q:\n", src.Name, dst.Name)
+	if err := ui.Table([]string{"Database", "Size", "Destination"}, rows); err != nil {
+		return err
+	}
+
+	var globals *psql.GlobalsPlan
+	if dst.Globals.Enabled {
+		if globals, err = planGlobals(ctx, src, dst); err != nil {
+			return err
+		}
+	}
+
+	msg := fmt.Sprintf("%d databases on DESTINATION %q will be WIPED and replaced from %q", len(selected)-created, dst.Name, src.Name)
+	if created > 0 {
+		msg += fmt.Sprintf("; %d will be created", created)
+	}
+	ok, err := o.confirm(c, []*config.Source{&dst}, msg+". Continue?")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Aborted.")
+		return nil
+	}
+
+	report := make([][]string, 0, len(selected))
+	failed := 0
+	for _, d := range selected {
+		if ctx.Err() != nil {
+			report = append(report, []string{d.Name, "", pterm.FgGray.Sprint("not started")})
+			continue
+		}
+		pterm.DefaultSection.Println(d.Name)
+		_, exists := existing[d.Name]
+		start := time.Now()
+		err := copyDatabase(ctx, c, src, dst, d.Name, !exists, globals, o.wait)
+		took := time.Since(start).Round(100 * time.Millisecond).String()
+		if err != nil {
+			failed++
+			pterm.Error.Printfln("%s: %v", d.Name, err)
+			msg, _, _ := strings.Cut(err.Error(), "\n")
+			report = append(report, []string{d.Name, took, pterm.FgRed.Sprintf("failed: %s", msg)})
+			continue
+		}
+		// Roles are cluster-wide; once copied they are there for every database.
+		globals = nil
+		result := pterm.FgGreen.Sprint("ok")
+		if !exists {
+			result += ", created"
+		}
+		report = append(report, []string{d.Name, took, result})
+	}
+
+	fmt.Println()
+	fmt.Println("Databases:")
+	ui.Table([]string{"Database", "Took", "Result"}, report)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d databases failed", failed, len(selected))
+	}
+	if ctx.Err() != nil {
+		return errors.New("interrupted")
+	}
+	return nil
+}
+
+// copyDatabase refreshes one database of a server-level destination from the
+// database with the same name on the source, creating it first if asked to.
+func copyDatabase(ctx context.Context, c config.Config, src, dst config.Source, name string, create bool, globals *psql.GlobalsPlan, wait bool) error {
+	ctx, cancel := context.WithTimeout(ctx, psql.DefaultTimeout)
+	defer cancel()
+	s, d := src.Database(name), dst.Database(name)
+	if err := checkPolicies(c, s.Name, d.Name, config.ModeFull); err != nil {
+		return err
+	}
+	if create {
+		if err := psql.CreateDatabase(ctx, toConn(dst), name); err != nil {
+			return err
+		}
+	}
+	lock, err := lockDestination(ctx, d, wait)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	if err := checkWipeWindows(c, d.Name, config.ModeFull); err != nil {
+		return err
+	}
+
+	t := &transfer{cfg: c, src: &s, dst: &d, mode: config.ModeFull, globals: globals}
+	return t.run(ctx)
+}
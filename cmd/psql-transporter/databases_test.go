@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jayps/psql-transporter/internal/config"
+	"github.com/jayps/psql-transporter/internal/policy"
+)
+
+func TestCopyDatabasePolicies(t *testing.T) {
+	src := config.Source{Name: "staging-db", Server: true}
+	dst := config.Source{Name: "dev-db", Server: true}
+	tests := []struct {
+		name     string
+		policies config.Policies
+		want     string
+	}{
+		{
+			name:     "database may not be exported",
+			policies: config.Policies{NoExport: []string{"staging-db/billing"}},
+			want:     `source "staging-db/billing" may not be exported`,
+		},
+		{
+			name: "pair allowed for the servers only",
+			policies: config.Policies{AllowedPairs: []config.PairRule{
+				{From: []string{"staging-db"}, To: []string{"dev-db"}},
+			}},
+			want: "staging-db/billing → dev-db/billing is not an allowed pair",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := config.Config{Policies: tt.policies}
+			err := copyDatabase(context.Background(), c, src, dst, "billing", true, nil, false)
+			var v *policy.Violation
+			if !errors.As(err, &v) || !strings.Contains(v.Reason, tt.want) {
+				t.Fatalf("got %v, want a violation containing %q", err, tt.want)
+			}
+		})
+	}
+}
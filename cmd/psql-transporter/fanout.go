@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pterm/pterm"
+
+	"github.com/jayps/psql-transporter/internal/config"
+	"github.com/jayps/psql-transporter/internal/hooks"
+	"github.com/jayps/psql-transporter/internal/psql"
+	"github.com/jayps/psql-transporter/internal/ui"
+)
+
+// runFanOut shows what refreshing each of dsts destroys, asks once for all of them and
+// runs fanOut.
+func runFanOut(ctx context.Context, c config.Config, src *config.Source, srcFile string, dsts []*config.Source, o transferOptions) error {
+	if o.parallel < 1 {
+		return fmt.Errorf("--parallel must be at least 1")
+	}
+	globals := make(map[string]*psql.GlobalsPlan)
+	names := make([]string, len(dsts))
+	for i, dst := range dsts {
+		names[i] = dst.Name
+		if dst.KeepOwners {
+			return fmt.Errorf("destination %q keeps the source's owners, which needs a dump of its own; refresh it separately", dst.Name)
+		}
+		pterm.DefaultSection.Println(dst.Name)
+		if src == nil {
+			showImpact(ctx, *dst, psql.WipeSchema, func() ([]string, error) { return psql.DumpTables(srcFile) })
+			continue
+		}
+		showImpact(ctx, *dst, psql.WipeSchema, func() ([]string, error) { return sourceTables(ctx, *src) })
+		if dst.Globals.Enabled {
+			plan, err := planGlobals(ctx, *src, *dst)
+			if err != nil {
+				return err
+			}
+			globals[dst.Name] = plan
+		}
+	}
+
+	from := srcFile
+	if src != nil {
+		from = src.Name
+	}
+	ok, err := o.confirm(c, dsts, fmt.Sprintf("DESTINATIONS %s will be WIPED and replaced with %q. Continue?", strings.Join(names, ", "), from))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Aborted.")
+		return nil
+	}
+	return fanOut(ctx, c, src, srcFile, dsts, globals, o.parallel, o.wait)
+}
+
+// confirmWipeAll is confirmWipe for several destinations at once: one question, and
+// the name of every destination that needs typed confirmation typed in turn.
+func confirmWipeAll(c config.Config, dsts []*config.Source, msg string) (bool, error) {
+	var typed []string
+	for _, dst := range dsts {
+		need, err := c.NeedsTypedConfirmation(*dst)
+		if err != nil {
+			return false, err
+		}
+		if need {
+			typed = append(typed, dst.Name)
+		}
+	}
+	if len(typed) == 0 {
+		return ui.ConfirmDanger(msg)
+	}
+	for _, name := range typed {
+		ok, err := ui.ConfirmTyped(msg, name)
+		if err != nil || !ok {
+			if err == nil {
+				fmt.Println("Name did not match.")
+			}
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// fanOutTarget is one destination of a fan-out run.
+type fanOutTarget struct {
+	t     *transfer
+	lock  *psql.DestLock
+	kept  keptTables
+	start time.Time
+	err   error
+}
+
+// fanOut refreshes every destination in dsts from a single export of src, or from the
+// dump file srcFile when src is nil. Destinations are wiped and imported up to
+// parallel at a time, with their progress shown side by side; the steps before and
+// after that run one destination at a time. A failing destination doesn't stop the
+// others, and one report covers them all. It assumes policies were checked and the
+// wipe was confirmed.
+func fanOut(ctx context.Context, c config.Config, src *config.Source, srcFile string, dsts []*config.Source, globals map[string]*psql.GlobalsPlan, parallel int, wait bool) error {
+	logFile, err := os.OpenFile(c.LogPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	dumpPath := srcFile
+	if src != nil {
+		dumpPath = filepath.Join(".", "dump.sql")
+	}
+	targets := make([]*fanOutTarget, len(dsts))
+	for i, dst := range dsts {
+		targets[i] = &fanOutTarget{t: &transfer{
+			cfg: c, src: src, srcFile: srcFile, dst: dst, mode: config.ModeFull,
+			globals: globals[dst.Name], dumpPath: dumpPath, log: logFile,
+		}}
+	}
+	defer func() {
+		for _, g := range targets {
+			if g.lock != nil {
+				g.lock.Release()
+			}
+		}
+	}()
+	pending := func() []*fanOutTarget {
+		var out []*fanOutTarget
+		for _, g := range targets {
+			if g.err == nil {
+				out = append(out, g)
+			}
+		}
+		return out
+	}
+
+	// Lock and check every destination before the export, so that a busy or
+	// misconfigured destination is left out early.
+	for _, g := range targets {
+		g.start = time.Now()
+		g.err = g.prepare(ctx, wait)
+	}
+	if len(pending()) == 0 {
+		return fanOutReport(ctx, targets)
+	}
+
+	if src != nil {
+		exp := &transfer{cfg: c, src: src, dstFile: dumpPath, dumpPath: dumpPath, log: logFile}
+		err := exp.hook(ctx, hooks.BeforeExport, nil)
+		if err == nil {
+			err = export(ctx, *src, dumpPath, psql.DumpOptions{})
+		}
+		if err == nil {
+			err = exp.hook(ctx, hooks.AfterExport, nil)
+		}
+		if err != nil {
+			for _, g := range pending() {
+				g.err = err
+			}
+			return fanOutReport(ctx, targets)
+		}
+	}
+
+	for _, g := range pending() {
+		if err := ctx.Err(); err != nil {
+			g.err = err
+			continue
+		}
+		pterm.DefaultSection.Println(g.t.dst.Name)
+		kept, err := saveKeptTables(ctx, *g.t.dst)
+		if err != nil {
+			g.err = err
+			continue
+		}
+		g.kept = kept
+		if err := g.t.hook(ctx, hooks.BeforeWipe, nil); err != nil {
+			g.err = keptDataHint(kept, err)
+		}
+	}
+
+	loading := pending()
+	if len(loading) > 0 {
+		names := make([]string, len(loading))
+		for i, g := range loading {
+			names[i] = g.t.dst.Name
+		}
+		fmt.Printf("Loading %d destinations, %d at a time:\n", len(loading), parallel)
+		board := ui.StartBoard(names, pterm.FgGray.Sprint("waiting"))
+		sem := make(chan struct{}, parallel)
+		var wg sync.WaitGroup
+		for i, g := range loading {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				g.err = g.load(ctx, func(state string) { board.Set(i, state) })
+				if g.err != nil {
+					msg, _, _ := strings.Cut(g.err.Error(), "\n")
+					board.Set(i, pterm.FgRed.Sprint("failed: "+msg))
+					g.err = keptDataHint(g.kept, g.err)
+					return
+				}
+				board.Set(i, pterm.FgGreen.Sprint("imported"))
+			}()
+		}
+		wg.Wait()
+		board.Stop()
+	}
+
+	for _, g := range pending() {
+		pterm.DefaultSection.Println(g.t.dst.Name)
+		g.err = g.t.finishImport(ctx, *g.t.dst, g.kept)
+	}
+	return fanOutReport(ctx, targets)
+}
+
+// prepare locks the destination, copies globals and checks roles.
+func (g *fanOutTarget) prepare(ctx context.Context, wait bool) error {
+	dst := *g.t.dst
+	if err := checkMaintenance(dst); err != nil {
+		return err
+	}
+	lock, err := lockDestination(ctx, dst, wait)
+	if err != nil {
+		return err
+	}
+	g.lock = lock
+	if err := checkWipeWindows(g.t.cfg, dst.Name, g.t.mode); err != nil {
+		return err
+	}
+	if err := g.t.applyGlobals(ctx); err != nil {
+		return err
+	}
+	return g.t.checkRoles(ctx)
+}
+
+// load wipes the destination and imports the dump, reporting progress through state.
+func (g *fanOutTarget) load(ctx context.Context, state func(string)) error {
+	conn := toConn(*g.t.dst)
+	state("wiping")
+	if err := psql.Wipe(ctx, conn); err != nil {
+		return err
+	}
+	state("importing")
+	return psql.ImportWithProgress(ctx, conn, g.t.dumpPath, func(done, total int64) {
+		if total > 0 {
+			state(fmt.Sprintf("importing %.1f%%", float64(done)/float64(total)*100))
+		}
+	})
+}
+
+// fanOutReport runs the failure hooks of the destinations that failed and prints
+// one line per destination.
+func fanOutReport(ctx context.Context, targets []*fanOutTarget) error {
+	rows := make([][]string, len(targets))
+	failed := 0
+	for i, g := range targets {
+		took := time.Since(g.start).Round(100 * time.Millisecond).String()
+		result := pterm.FgGreen.Sprint("ok")
+		if g.err != nil {
+			failed++
+			msg, _, _ := strings.Cut(g.err.Error(), "\n")
+			result = pterm.FgRed.Sprint("failed: " + msg)
+			hctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Minute)
+			if herr := g.t.hook(hctx, hooks.OnFailure, g.err); herr != nil {
+				pterm.Warning.Println(herr)
+			}
+			cancel()
+		}
+		rows[i] = []string{g.t.dst.Name, took, result}
+	}
+	fmt.Println()
+	fmt.Println("Destinations:")
+	ui.Table([]string{"Destination", "Took", "Result"}, rows)
+	for _, g := range targets {
+		if g.err != nil && len(g.kept.tables) > 0 {
+			fmt.Printf("%s: %v\n", g.t.dst.Name, g.err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d destinations failed", failed, len(targets))
+	}
+	fmt.Println("All done ✅")
+	return nil
+}
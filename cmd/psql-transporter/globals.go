@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pterm/pterm"
+
+	"github.com/jayps/psql-transporter/internal/config"
+	"github.com/jayps/psql-transporter/internal/psql"
+	"github.com/jayps/psql-transporter/internal/ui"
+)
+
+// planGlobals dumps the source server's roles and tablespaces, filters them with the
+// destination's globals settings and prints which roles will be created, altered or
+// skipped. The destination's own user is never touched.
+func planGlobals(ctx context.Context, src, dst config.Source) (*psql.GlobalsPlan, error) {
+	g := dst.Globals
+	plan, err := ui.StepSpinner("Reading roles from source...", func() (psql.GlobalsPlan, error) {
+		dump, err := psql.DumpGlobals(ctx, toConn(src), g.Passwords)
+		if err != nil {
+			return psql.GlobalsPlan{}, err
+		}
+		plan := psql.PlanGlobals(dump, psql.GlobalsFilter{
+			Roles:       g.Roles,
+			Superusers:  g.Superusers,
+			Replication: g.Replication,
+			Tablespaces: g.Tablespaces,
+			Skip:        []string{dst.User},
+		})
+		return plan, psql.CheckGlobals(ctx, toConn(dst), &plan)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("globals: %w", err)
+	}
+
+	rows := make([][]string, 0, len(plan.Roles))
+	for _, r := range plan.Roles {
+		action := pterm.FgGreen.Sprint("create")
+		switch {
+		case r.Skipped != "":
+			action = pterm.FgGray.Sprintf("skip (%s)", r.Skipped)
+		case r.Exists:
+			action = pterm.FgYellow.Sprint("alter")
+		}
+		attrs := strings.Join(r.Attributes, " ")
+		if len(r.Member) > 0 {
+			attrs = strings.TrimSpace(attrs + " IN " + strings.Join(r.Member, ", "))
+		}
+		rows = append(rows, []string{r.Name, action, attrs})
+	}
+	if len(rows) == 0 {
+		fmt.Printf("Source %q has no roles to copy.\n", src.Name)
+	} else {
+		fmt.Printf("Roles from %q to DESTINATION %q:\n", src.Name, dst.Name)
+		if err := ui.Table([]string{"Role", "Action", "Attributes"}, rows); err != nil {
+			return nil, err
+		}
+	}
+	if len(plan.Tablespaces) > 0 {
+		fmt.Printf("Tablespaces created if missing: %s\n", strings.Join(plan.Tablespaces, ", "))
+	}
+	return &plan, nil
+}
+
+// applyGlobals creates and updates the planned roles and tablespaces on dst.
+func (t *transfer) applyGlobals(ctx context.Context) error {
+	if t.globals == nil || t.globals.Empty() {
+		return nil
+	}
+	return t.timed("Globals", func() (string, error) {
+		_, err := ui.StepSpinner("Copying roles...", func() (any, error) {
+			return nil, psql.ApplyGlobals(ctx, toConn(*t.dst), *t.globals)
+		})
+		created, altered := 0, 0
+		for _, r := range t.globals.Roles {
+			switch {
+			case r.Skipped != "":
+			case r.Exists:
+				altered++
+			default:
+				created++
+			}
+		}
+		return fmt.Sprintf("%d created, %d altered", created, altered), err
+	})
+}
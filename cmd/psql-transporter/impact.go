@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pterm/pterm"
+
+	"github.com/jayps/psql-transporter/internal/config"
+	"github.com/jayps/psql-transporter/internal/psql"
+	"github.com/jayps/psql-transporter/internal/ui"
+)
+
+// showImpact prints what wiping schema on dst will destroy: every table in it with its
+// estimated rows and size, highlighting tables the incoming data does not bring back
+// and marking the destination's keep_tables. incoming lists the tables of the source
+// database or dump file, qualified with schema.
+// Failures are reported as warnings so they never block the confirmation prompt.
+func showImpact(ctx context.Context, dst config.Source, schema string, incoming func() ([]string, error)) {
+	tables, err := ui.StepSpinner("Inspecting destination...", func() ([]psql.TableInfo, error) {
+		return psql.Tables(ctx, toConn(dst), []string{schema})
+	})
+	if err != nil {
+		pterm.Warning.Println("Could not preview the destination; continuing without it.")
+		return
+	}
+	if len(tables) == 0 {
+		fmt.Printf("DESTINATION %q has no tables in schema %q; nothing will be lost.\n", dst.Name, schema)
+		return
+	}
+
+	names, err := incoming()
+	if err != nil {
+		pterm.Warning.Printfln("Could not list incoming tables: %v", err)
+		names = nil
+	}
+
+	var totalRows, totalBytes int64
+	missing := 0
+	impact := tableImpacts(dst, tables, names)
+	rows := make([][]string, 0, len(impact))
+	for _, t := range impact {
+		rowCount := "?"
+		if t.Rows >= 0 {
+			rowCount = fmt.Sprintf("~%d", t.Rows)
+			totalRows += t.Rows
+		}
+		totalBytes += t.Bytes
+		status := t.Status
+		switch t.Status {
+		case impactKept:
+			status = pterm.FgGreen.Sprintf("kept (%s)", t.Keep)
+		case impactLost:
+			status = pterm.FgRed.Sprint("NOT IN SOURCE")
+			missing++
+		}
+		rows = append(rows, []string{t.Table, rowCount, humanSize(t.Bytes), status})
+	}
+
+	fmt.Printf("DESTINATION %q currently holds:\n", dst.Name)
+	if err := ui.Table([]string{"Table", "Rows (est.)", "Size", "After import"}, rows); err != nil {
+		return
+	}
+	fmt.Printf("%d tables, ~%d rows, %s will be dropped.\n", len(tables), totalRows, humanSize(totalBytes))
+	if missing > 0 {
+		pterm.Warning.Printfln("%d table(s) exist only in the destination and will be lost for good.", missing)
+	}
+}
+
+// What becomes of a destination table when its schema is wiped.
+const (
+	impactReplaced = "replaced"      // the incoming data brings it back
+	impactKept     = "kept"          // one of the destination's keep_tables
+	impactLost     = "not in source" // gone for good
+)
+
+// tableImpact is what a transfer does to one destination table.
+type tableImpact struct {
+	Table  string `json:"table"`
+	Rows   int64  `json:"rows"` // planner estimate; -1 if the table was never analyzed
+	Bytes  int64  `json:"bytes"`
+	Status string `json:"status"`         // impactReplaced, impactKept or impactLost
+	Keep   string `json:"keep,omitempty"` // how a kept table is restored
+}
+
+// tableImpacts tells what wiping tables, the tables of a schema of dst, does to each.
+// incoming lists the tables the transfer brings, qualified with schema; nil means
+// unknown, in which case no table counts as lost.
+func tableImpacts(dst config.Source, tables []psql.TableInfo, incoming []string) []tableImpact {
+	incomingSet := make(map[string]bool, len(incoming))
+	for _, n := range incoming {
+		incomingSet[n] = true
+	}
+
+	keep := make(map[string]string, len(dst.KeepTables))
+	for _, k := range dst.KeepTables {
+		name := k.Table
+		if !strings.Contains(name, ".") {
+			name = psql.WipeSchema + "." + name
+		}
+		mode := k.Mode
+		if mode == "" {
+			mode = psql.KeepUpsert
+		}
+		keep[name] = mode
+	}
+
+	out := make([]tableImpact, len(tables))
+	for i, t := range tables {
+		out[i] = tableImpact{Table: t.QualifiedName(), Rows: t.Rows, Bytes: t.Bytes, Status: impactReplaced}
+		if mode, ok := keep[t.QualifiedName()]; ok {
+			out[i].Status, out[i].Keep = impactKept, mode
+		} else if incoming != nil && !incomingSet[t.QualifiedName()] {
+			out[i].Status = impactLost
+		}
+	}
+	return out
+}
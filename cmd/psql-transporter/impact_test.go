@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jayps/psql-transporter/internal/config"
+	"github.com/jayps/psql-transporter/internal/psql"
+)
+
+func TestTableImpacts(t *testing.T) {
+	tables := []psql.TableInfo{
+		{Schema: "public", Name: "users", Rows: 10, Bytes: 8192},
+		{Schema: "public", Name: "sessions", Rows: -1, Bytes: 0},
+		{Schema: "audit", Name: "events", Rows: 5, Bytes: 4096},
+	}
+	tests := []struct {
+		name     string
+		keep     []config.KeepTable
+		incoming []string
+		want     []string // status of each table, with the keep mode of kept ones
+	}{
+		{
+			name:     "every table comes back",
+			incoming: []string{"public.users", "public.sessions", "audit.events"},
+			want:     []string{impactReplaced, impactReplaced, impactReplaced},
+		},
+		{
+			name:     "tables missing from the source are lost",
+			incoming: []string{"public.users"},
+			want:     []string{impactReplaced, impactLost, impactLost},
+		},
+		{
+			name: "incoming tables unknown",
+			want: []string{impactReplaced, impactReplaced, impactReplaced},
+		},
+		{
+			name:     "no incoming tables",
+			incoming: []string{},
+			want:     []string{impactLost, impactLost, impactLost},
+		},
+		{
+			name:     "keep_tables without a schema are in public",
+			keep:     []config.KeepTable{{Table: "sessions"}, {Table: "events", Mode: psql.KeepReplace}},
+			incoming: []string{"public.users"},
+			want:     []string{impactReplaced, impactKept + " " + psql.KeepUpsert, impactLost},
+		},
+		{
+			name:     "keep_tables with a schema",
+			keep:     []config.KeepTable{{Table: "audit.events", Mode: psql.KeepReplace}},
+			incoming: []string{"public.users", "public.sessions"},
+			want:     []string{impactReplaced, impactReplaced, impactKept + " " + psql.KeepReplace},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tableImpacts(config.Source{Name: "dev", KeepTables: tt.keep}, tables, tt.incoming)
+			if len(got) != len(tables) {
+				t.Fatalf("got %d impacts, want %d", len(got), len(tables))
+			}
+			statuses := make([]string, len(got))
+			for i, im := range got {
+				if im.Table != tables[i].QualifiedName() || im.Rows != tables[i].Rows || im.Bytes != tables[i].Bytes {
+					t.Errorf("impact %d = %+v, want it to describe %+v", i, im, tables[i])
+				}
+				statuses[i] = im.Status
+				if im.Keep != "" {
+					statuses[i] += " " + im.Keep
+				}
+			}
+			if !reflect.DeepEqual(statuses, tt.want) {
+				t.Errorf("statuses = %q, want %q", statuses, tt.want)
+			}
+		})
+	}
+}
@@ -1,35 +1,53 @@
 package main
 
 import (
+	"context"
 	"errors"
-	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"os/user"
 	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 
 	"github.com/jayps/psql-transporter/internal/config"
+	"github.com/jayps/psql-transporter/internal/policy"
 	"github.com/jayps/psql-transporter/internal/psql"
 	"github.com/jayps/psql-transporter/internal/ui"
 )
 
 var version = "dev" // overridden by -ldflags "-X main.version=..."
 
-func main() {
-	showVersion := flag.Bool("v", false, "Print version and exit")
-	flag.BoolVar(showVersion, "version", false, "Print version and exit")
-	flag.Parse()
-
-	if *showVersion {
-		fmt.Println("psql-transporter version:", version)
-		os.Exit(0)
-	}
+// transferOptions is what a transfer is asked to do, from flags or a recipe.
+type transferOptions struct {
+	source        string   // source name; empty prompts for one
+	file          string   // dump file to load instead of a source
+	to            []string // destination names; empty prompts for one
+	mode          string
+	mergeTables   []string
+	deleteMissing bool
+	full          bool
+	intoSchema    string
+	allDatabases  bool
+	databases     []string
+	parallel      int
+	wait          bool
+	unattended    bool     // nothing is asked; see confirm
+	confirmed     []string // unattended: destinations whose name was typed in advance
+}
 
+func main() {
+	var o transferOptions
 	root := &cobra.Command{
-		Use:   "psql-transporter",
-		Short: "DB export/import helper for Postgres",
+		Use:     "psql-transporter",
+		Short:   "DB export/import helper for Postgres",
+		Version: version,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfgPath, created, err := config.EnsureExists(".")
 			if err != nil {
@@ -40,211 +58,525 @@ func main() {
 				fmt.Println("Edit it and re-run.")
 				return nil
 			}
-
-			c, err := config.Load(cfgPath)
+			c, err := loadConfig(cfgPath)
 			if err != nil {
 				return err
 			}
-
-			names := make([]string, len(c.Sources))
-			for i, s := range c.Sources {
-				names[i] = s.Name
-			}
-			loadFromFileOption := "Load from file"
-			namesSrc := make([]string, 0, len(names)+1)
-			namesSrc = append(namesSrc, names...)
-			namesSrc = append(namesSrc, loadFromFileOption)
-			srcSel, err := ui.Select("Select SOURCE:", namesSrc)
-			if err != nil {
-				return err
-			}
-
-			var src *config.Source
-			srcIsFile := false
-			srcFile := ""
-			if srcSel == loadFromFileOption {
-				// Source is a dump file
-				defPath := filepath.Join(".", "dump.sql")
-				filePath, err := ui.InputExistingFile("Enter input dump file path:", defPath)
+			// Recipes come first, unless flags already describe the transfer.
+			if len(c.Recipes) > 0 && cmd.Flags().NFlag() == 0 {
+				r, ok, err := pickRecipe(c)
 				if err != nil {
 					return err
 				}
-				srcIsFile = true
-				srcFile = filePath
-			} else {
-				// Find source DB
-				for i := range c.Sources {
-					if c.Sources[i].Name == srcSel {
-						src = &c.Sources[i]
-						break
-					}
-				}
-				if src == nil {
-					return errors.New("invalid source selection")
+				if ok {
+					return runRecipe(c, r)
 				}
 			}
+			return runTransfer(context.Background(), c, o)
+		},
+	}
 
-			// Build destination options (omit protected databases and the same as source)
-			dumpToFileOption := "Dump to file"
-			namesDst := make([]string, 0, len(names)+1)
-			for _, s := range c.Sources {
-				// skip protected databases for destination choices
-				if s.Protected {
-					continue
-				}
-				// if source is a DB, do not allow selecting the same DB as destination
-				if !srcIsFile && src != nil && s.Name == src.Name {
-					continue
-				}
-				namesDst = append(namesDst, s.Name)
-			}
-			if !srcIsFile {
-				// Only allow dump-to-file when source is a DB
-				namesDst = append(namesDst, dumpToFileOption)
-			}
-			dstName, err := ui.Select("Select DESTINATION:", namesDst)
-			if err != nil {
-				return err
-			}
-
-			ctx, cancel := psql.DefaultTimeoutCtx()
-			defer cancel()
+	root.SetVersionTemplate("psql-transporter version: {{.Version}}\n")
+	root.Flags().BoolP("version", "v", false, "Print version and exit")
+	root.Flags().StringVar(&o.mode, "mode", config.ModeFull, "Transfer mode: "+strings.Join(config.Modes, ", "))
+	root.Flags().StringSliceVar(&o.mergeTables, "tables", nil, "Tables to merge in merge mode (overrides the destination's merge.tables)")
+	root.Flags().BoolVar(&o.deleteMissing, "delete-missing", false, "In merge mode, delete destination rows whose key is not in the source")
+	root.Flags().BoolVar(&o.full, "full", false, "In incremental mode, ignore the stored watermarks and resync every row")
+	root.Flags().StringVar(&o.intoSchema, "into-schema", "", "Copy the source's public schema into this schema instead, leaving the rest of the destination alone")
+	root.Flags().BoolVar(&o.allDatabases, "all-databases", false, "Copy every database of a server-level source to a server-level destination")
+	root.Flags().StringSliceVar(&o.databases, "databases", nil, "Copy these databases of a server-level source to a server-level destination")
+	root.Flags().StringSliceVar(&o.to, "to", nil, "Destination(s) instead of the prompt; several are refreshed from one export")
+	root.Flags().IntVar(&o.parallel, "parallel", 2, "With several destinations, how many to wipe and import at a time")
+	root.Flags().BoolVar(&o.wait, "wait", false, "Wait for another run refreshing the destination to finish instead of failing")
+	root.AddCommand(newRunCmd(), newPlanCmd(), newApplyCmd(), newDaemonCmd(), newServeCmd(), newServeRunCmd(), newConfigCmd(), newReplicateCmd(), newSchemaCmd())
 
-			if !srcIsFile && dstName == dumpToFileOption {
-				// DB -> File (export only)
-				defPath := filepath.Join(".", "dump.sql")
-				filePath, err := ui.Input("Enter output dump file path:", defPath)
-				if err != nil {
-					return err
-				}
-				// Use a spinner and update its text with the file size as the dump progresses
-				spinner, _ := pterm.DefaultSpinner.Start("Exporting...")
-				err = psql.DumpWithProgress(ctx, toConn(*src), filePath, func(sz int64) {
-					spinner.UpdateText(fmt.Sprintf("Exporting... (%s)", humanSize(sz)))
-				})
-				if err != nil {
-					spinner.Fail(fmt.Sprintf("Export failed: %v", err))
-					return err
-				}
-				spinner.Success("Export completed")
-				fmt.Println("Dump written to", filePath)
-				fmt.Println("All done ✅")
-				return nil
-			}
+	if err := root.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
 
-			// Destination must be a DB at this point
-			var dst *config.Source
-			for i := range c.Sources {
-				if c.Sources[i].Name == dstName {
-					dst = &c.Sources[i]
-					break
-				}
-			}
-			if dst == nil {
-				return errors.New("invalid destination selection")
-			}
-			if dst.Protected {
-				return fmt.Errorf("destination %q is protected; aborting", dst.Name)
-			}
+// runTransfer runs one transfer as o asks, prompting for whatever o leaves open.
+func runTransfer(ctx context.Context, c config.Config, o transferOptions) error {
+	var err error
+	if o.unattended && (o.source == "" && o.file == "" || len(o.to) == 0) {
+		return errors.New("an unattended transfer needs a source and a destination")
+	}
+	if o.mode, err = config.ParseMode(o.mode); err != nil {
+		return err
+	}
+	if o.intoSchema == psql.WipeSchema {
+		o.intoSchema = ""
+	}
+	if o.intoSchema != "" {
+		if err := psql.CheckSchemaName(o.intoSchema); err != nil {
+			return err
+		}
+		if o.mode != config.ModeFull {
+			return fmt.Errorf("--into-schema works with mode %s only", config.ModeFull)
+		}
+	}
+	// Copying several databases offers only server-level sources; a single transfer offers only databases.
+	multi := o.allDatabases || len(o.databases) > 0
+	if multi {
+		if o.mode != config.ModeFull || o.intoSchema != "" {
+			return fmt.Errorf("--all-databases and --databases work with mode %s only", config.ModeFull)
+		}
+		if o.allDatabases && len(o.databases) > 0 {
+			return errors.New("pass either --all-databases or --databases, not both")
+		}
+	}
 
-			if !srcIsFile && src.Name == dst.Name {
-				return fmt.Errorf("source and destination cannot be the same")
-			}
+	names := make([]string, 0, len(c.Sources))
+	for _, s := range c.Sources {
+		if s.Server == multi {
+			names = append(names, s.Name)
+		}
+	}
+	if len(names) == 0 && multi {
+		return errors.New("no server-level sources in the config; set server: true on one")
+	}
+	loadFromFileOption := "Load from file"
+	namesSrc := make([]string, 0, len(names)+1)
+	namesSrc = append(namesSrc, names...)
+	if !multi {
+		namesSrc = append(namesSrc, loadFromFileOption)
+	}
+	srcSel := o.source
+	if o.file != "" {
+		srcSel = loadFromFileOption
+	}
+	if srcSel == "" {
+		if srcSel, err = ui.SelectTagged("Select SOURCE:", namesSrc, envTag(c)); err != nil {
+			return err
+		}
+	} else if !slices.Contains(namesSrc, srcSel) {
+		return fmt.Errorf("%q is not a valid source here (want one of %s)", srcSel, strings.Join(names, ", "))
+	}
 
-			// Confirm destructive action
-			var confirmMsg string
-			if srcIsFile {
-				confirmMsg = fmt.Sprintf("DESTINATION %q will be WIPED and replaced with contents of %q. Continue?", dst.Name, srcFile)
-			} else {
-				confirmMsg = fmt.Sprintf("DESTINATION %q will be WIPED and replaced with %q. Continue?", dst.Name, src.Name)
-			}
-			ok, err := ui.ConfirmDanger(confirmMsg)
-			if err != nil {
+	var src *config.Source
+	srcIsFile := false
+	srcFile := ""
+	if srcSel == loadFromFileOption {
+		// Source is a dump file
+		filePath := o.file
+		if filePath == "" {
+			defPath := filepath.Join(".", "dump.sql")
+			if filePath, err = ui.InputExistingFile("Enter input dump file path:", defPath); err != nil {
 				return err
 			}
-			if !ok {
-				fmt.Println("Aborted.")
-				return nil
+		}
+		if o.mode != config.ModeFull {
+			return fmt.Errorf("%s mode needs a source database, not a dump file", o.mode)
+		}
+		if o.intoSchema != "" {
+			return errors.New("--into-schema needs a source database, not a dump file")
+		}
+		srcIsFile = true
+		srcFile = filePath
+	} else {
+		// Find source DB
+		for i := range c.Sources {
+			if c.Sources[i].Name == srcSel {
+				src = &c.Sources[i]
+				break
 			}
+		}
+		if src == nil {
+			return errors.New("invalid source selection")
+		}
+	}
 
-			if srcIsFile {
-				// File -> DB: wipe then import from file with progress
-				if err := ui.RunSteps([]ui.Step{
-					{Title: "Wiping destination...", Run: func() error { return psql.Wipe(ctx, toConn(*dst)) }},
-				}); err != nil {
-					return err
-				}
-				spinner, _ := pterm.DefaultSpinner.Start("Importing...")
-				err := psql.ImportWithProgress(ctx, toConn(*dst), srcFile, func(done, total int64) {
-					var text string
-					if total > 0 {
-						pct := float64(done) / float64(total) * 100
-						text = fmt.Sprintf("Importing... (%.1f%%)", pct)
-					} else {
-						text = fmt.Sprintf("Importing... (%s)", humanSize(done))
-					}
-					spinner.UpdateText(text)
-				})
-				if err != nil {
-					spinner.Fail(fmt.Sprintf("Import failed: %v", err))
-					return err
-				}
-				spinner.Success("Import completed")
-				fmt.Println("All done ✅")
-				return nil
-			}
+	// Build destination options (omit protected databases and the same as source)
+	dumpToFileOption := "Dump to file"
+	namesDst := make([]string, 0, len(names)+1)
+	for _, s := range c.Sources {
+		// skip protected databases for destination choices
+		if s.Protected || s.Server != multi {
+			continue
+		}
+		// if source is a DB, do not allow selecting the same DB as destination,
+		// unless its public schema is being cloned into another schema
+		if !srcIsFile && src != nil && s.Name == src.Name && o.intoSchema == "" {
+			continue
+		}
+		namesDst = append(namesDst, s.Name)
+	}
+	if !srcIsFile && !multi {
+		// Only allow dump-to-file when source is a DB
+		namesDst = append(namesDst, dumpToFileOption)
+	}
+	dstNames := o.to
+	for i, n := range dstNames {
+		if slices.Contains(dstNames[:i], n) {
+			return fmt.Errorf("destination %q is listed twice", n)
+		}
+		if !slices.Contains(namesDst, n) {
+			return fmt.Errorf("%q is not a valid destination here (want one of %s)", n, strings.Join(namesDst, ", "))
+		}
+	}
+	if len(dstNames) > 1 {
+		switch {
+		case multi, o.mode != config.ModeFull, o.intoSchema != "":
+			return fmt.Errorf("several destinations work with a plain %s refresh only", config.ModeFull)
+		case slices.Contains(dstNames, dumpToFileOption):
+			return fmt.Errorf("%q can't be one of several destinations", dumpToFileOption)
+		}
+	}
+	if len(dstNames) == 0 {
+		dstName, err := ui.SelectTagged("Select DESTINATION:", namesDst, envTag(c))
+		if err != nil {
+			return err
+		}
+		dstNames = []string{dstName}
+	}
+	dstName := dstNames[0]
 
-			// DB -> DB flow (export, wipe, import)
-			dumpPath := filepath.Join(".", "dump.sql")
-			// Custom export step with progress shown in the spinner text
-			spinner, _ := pterm.DefaultSpinner.Start("Exporting...")
-			err = psql.DumpWithProgress(ctx, toConn(*src), dumpPath, func(sz int64) {
-				spinner.UpdateText(fmt.Sprintf("Exporting... (%s)", humanSize(sz)))
-			})
-			if err != nil {
-				spinner.Fail(fmt.Sprintf("Export failed: %v", err))
+	// Every transfer passes the configured policies before anything is exported or wiped.
+	for _, name := range dstNames {
+		policySrc, policyDst := policy.File, name
+		if !srcIsFile {
+			policySrc = src.Name
+		}
+		if name == dumpToFileOption {
+			policyDst = policy.File
+		}
+		if err := checkPolicies(c, policySrc, policyDst, o.mode); err != nil {
+			return err
+		}
+	}
+
+	if multi {
+		dst, err := sourceByName(c, dstName)
+		if err != nil {
+			return err
+		}
+		// Each database gets its own timeout; see copyDatabases.
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return copyDatabases(ctx, c, *src, *dst, o)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, psql.DefaultTimeout)
+	defer cancel()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if len(dstNames) > 1 {
+		dsts := make([]*config.Source, len(dstNames))
+		for i, name := range dstNames {
+			if dsts[i], err = sourceByName(c, name); err != nil {
 				return err
 			}
-			spinner.Success("Export completed")
-			if err := ui.RunSteps([]ui.Step{
-				{Title: "Wiping destination...", Run: func() error { return psql.Wipe(ctx, toConn(*dst)) }},
-			}); err != nil {
-				return err
+		}
+		return runFanOut(ctx, c, src, srcFile, dsts, o)
+	}
+
+	if !srcIsFile && dstName == dumpToFileOption {
+		// DB -> File (export only)
+		defPath := filepath.Join(".", "dump.sql")
+		filePath, err := ui.Input("Enter output dump file path:", defPath)
+		if err != nil {
+			return err
+		}
+		t := &transfer{cfg: c, src: src, dstFile: filePath, mode: o.mode, intoSchema: o.intoSchema}
+		return t.run(ctx)
+	}
+
+	// Destination must be a DB at this point
+	var dst *config.Source
+	for i := range c.Sources {
+		if c.Sources[i].Name == dstName {
+			dst = &c.Sources[i]
+			break
+		}
+	}
+	if dst == nil {
+		return errors.New("invalid destination selection")
+	}
+	if dst.Protected {
+		return fmt.Errorf("destination %q is protected; aborting", dst.Name)
+	}
+
+	if !srcIsFile && src.Name == dst.Name && o.intoSchema == "" {
+		return fmt.Errorf("source and destination cannot be the same")
+	}
+
+	var (
+		merge       config.Merge
+		incremental config.Incremental
+	)
+	switch o.mode {
+	case config.ModeMerge:
+		if merge, err = mergeSettings(*dst, o.mergeTables, o.deleteMissing); err != nil {
+			return err
+		}
+	case config.ModeIncremental:
+		if incremental, err = incrementalSettings(*dst); err != nil {
+			return err
+		}
+	}
+
+	// Show what the wipe destroys before asking; a merge leaves other tables alone.
+	switch {
+	case o.mode == config.ModeMerge, o.mode == config.ModeIncremental:
+	case o.intoSchema != "":
+		showImpact(ctx, *dst, o.intoSchema, func() ([]string, error) {
+			names, err := sourceTables(ctx, *src)
+			for i, n := range names {
+				names[i] = o.intoSchema + strings.TrimPrefix(n, psql.WipeSchema)
 			}
-			impSpinner, _ := pterm.DefaultSpinner.Start("Importing...")
-			err = psql.ImportWithProgress(ctx, toConn(*dst), dumpPath, func(done, total int64) {
-				var text string
-				if total > 0 {
-					pct := float64(done) / float64(total) * 100
-					text = fmt.Sprintf("Importing... (%.1f%%)", pct)
-				} else {
-					text = fmt.Sprintf("Importing... (%s)", humanSize(done))
-				}
-				impSpinner.UpdateText(text)
-			})
-			if err != nil {
-				impSpinner.Fail(fmt.Sprintf("Import failed: %v", err))
-				return err
+			return names, err
+		})
+	case srcIsFile:
+		showImpact(ctx, *dst, psql.WipeSchema, func() ([]string, error) { return psql.DumpTables(srcFile) })
+	default:
+		showImpact(ctx, *dst, psql.WipeSchema, func() ([]string, error) { return sourceTables(ctx, *src) })
+	}
+	var globals *psql.GlobalsPlan
+	if dst.Globals.Enabled && !srcIsFile && (o.mode == config.ModeFull || o.mode == config.ModeDataOnly) {
+		if globals, err = planGlobals(ctx, *src, *dst); err != nil {
+			return err
+		}
+	}
+
+	// Confirm destructive action
+	var confirmMsg string
+	switch {
+	case o.mode == config.ModeMerge:
+		confirmMsg = fmt.Sprintf("Tables %s in DESTINATION %q will be merged with rows from %q", strings.Join(merge.Tables, ", "), dst.Name, src.Name)
+		if merge.DeleteMissing {
+			confirmMsg += "; rows missing from the source will be DELETED"
+		}
+		confirmMsg += ". Continue?"
+	case o.mode == config.ModeIncremental:
+		tables := make([]string, len(incremental.Tables))
+		for i, w := range incremental.Tables {
+			tables[i] = w.Table
+		}
+		confirmMsg = fmt.Sprintf("Rows of %s changed since the last sync will be upserted into DESTINATION %q from %q. Continue?", strings.Join(tables, ", "), dst.Name, src.Name)
+		if o.full {
+			confirmMsg = fmt.Sprintf("All rows of %s will be upserted into DESTINATION %q from %q; rows missing from the source will be DELETED. Continue?", strings.Join(tables, ", "), dst.Name, src.Name)
+		}
+	case o.intoSchema != "":
+		confirmMsg = fmt.Sprintf("Schema %q in DESTINATION %q will be WIPED and replaced with the %s schema of %q. Continue?", o.intoSchema, dst.Name, psql.WipeSchema, src.Name)
+	case o.mode == config.ModeDataOnly:
+		confirmMsg = fmt.Sprintf("Tables in DESTINATION %q will be TRUNCATED and reloaded with data from %q (schema kept). Continue?", dst.Name, src.Name)
+	case srcIsFile:
+		confirmMsg = fmt.Sprintf("DESTINATION %q will be WIPED and replaced with contents of %q. Continue?", dst.Name, srcFile)
+	default:
+		confirmMsg = fmt.Sprintf("DESTINATION %q will be WIPED and replaced with %q. Continue?", dst.Name, src.Name)
+	}
+	ok, err := o.confirm(c, []*config.Source{dst}, confirmMsg)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Aborted.")
+		return nil
+	}
+
+	// Hold the destination lock from before the export until the import is done.
+	lock, err := lockDestination(ctx, *dst, o.wait)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	if err := checkWipeWindows(c, dst.Name, o.mode); err != nil {
+		return err
+	}
+
+	t := &transfer{cfg: c, src: src, srcFile: srcFile, dst: dst, mode: o.mode,
+		merge: merge, incremental: incremental, full: o.full, intoSchema: o.intoSchema, globals: globals,
+	}
+	return t.run(ctx)
+}
+
+// loadConfig brings the config file at path up to the current version, saying so
+// before the file is rewritten, and loads it.
+func loadConfig(path string) (config.Config, error) {
+	mig, err := config.Migrate(path, true)
+	if err != nil {
+		return config.Config{}, err
+	}
+	if mig.Changed() {
+		fmt.Printf("%s is written for config version %d; upgrading it to version %d (the original is kept as %s.v%d.bak)\n", path, mig.From, mig.To, path, mig.From)
+		if _, err := config.Migrate(path, false); err != nil {
+			return config.Config{}, err
+		}
+	}
+	return config.Load(path)
+}
+
+// sourceByName finds the configured database called name.
+func sourceByName(c config.Config, name string) (*config.Source, error) {
+	for i := range c.Sources {
+		if c.Sources[i].Name == name {
+			return &c.Sources[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no database named %q in the config", name)
+}
+
+// sourceTables lists the tables of src that a transfer copies.
+func sourceTables(ctx context.Context, src config.Source) ([]string, error) {
+	tables, err := psql.Tables(ctx, toConn(src), []string{psql.WipeSchema})
+	names := make([]string, len(tables))
+	for i, t := range tables {
+		names[i] = t.QualifiedName()
+	}
+	return names, err
+}
+
+// envTag returns a ui.SelectTagged tag function that labels each source with its
+// environment in the environment's color.
+func envTag(c config.Config) func(string) string {
+	return func(name string) string {
+		for _, s := range c.Sources {
+			if s.Name == name && s.Environment != "" {
+				return ui.Colorize(c.ColorOf(s), "["+s.Environment+"]")
 			}
-			impSpinner.Success("Import completed")
+		}
+		return ""
+	}
+}
 
-			fmt.Println("All done ✅")
-			return nil
-		},
+// confirm asks before wiping dsts. Unattended runs go ahead without asking, except
+// that they refuse destinations that must be confirmed by typing their name, unless
+// the name is in o.confirmed.
+func (o transferOptions) confirm(c config.Config, dsts []*config.Source, msg string) (bool, error) {
+	if !o.unattended {
+		if len(dsts) == 1 {
+			return confirmWipe(c, *dsts[0], msg)
+		}
+		return confirmWipeAll(c, dsts, msg)
 	}
+	for _, dst := range dsts {
+		typed, err := c.NeedsTypedConfirmation(*dst)
+		if err != nil {
+			return false, err
+		}
+		if typed && !slices.Contains(o.confirmed, dst.Name) {
+			return false, fmt.Errorf("destination %q must be confirmed by typing its name, so it can't be refreshed unattended", dst.Name)
+		}
+	}
+	answer := "Yes (unattended)"
+	if len(o.confirmed) > 0 {
+		answer = "Yes (confirmed in advance)"
+	}
+	fmt.Println(msg, answer)
+	return true, nil
+}
 
-	if err := root.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+// confirmWipe asks before wiping dst. Destinations at or above the configured risk
+// level must be confirmed by typing their name.
+func confirmWipe(c config.Config, dst config.Source, msg string) (bool, error) {
+	if dst.Environment != "" {
+		msg = ui.Colorize(c.ColorOf(dst), "["+strings.ToUpper(dst.Environment)+"] ") + msg
+	}
+	typed, err := c.NeedsTypedConfirmation(dst)
+	if err != nil {
+		return false, err
+	}
+	if !typed {
+		return ui.ConfirmDanger(msg)
+	}
+	ok, err := ui.ConfirmTyped(msg, dst.Name)
+	if err == nil && !ok {
+		fmt.Println("Name did not match.")
+	}
+	return ok, err
+}
+
+// mergeSettings resolves the tables to merge into dst: --tables wins over the
+// destination's merge.tables, and --delete-missing turns deletion on.
+func mergeSettings(dst config.Source, tables []string, deleteMissing bool) (config.Merge, error) {
+	var m config.Merge
+	if dst.Merge != nil {
+		m = *dst.Merge
+	}
+	if len(tables) > 0 {
+		m.Tables = tables
 	}
+	m.DeleteMissing = m.DeleteMissing || deleteMissing
+	if len(m.Tables) == 0 {
+		return m, fmt.Errorf("%s mode needs tables: pass --tables or set merge.tables on %q", config.ModeMerge, dst.Name)
+	}
+	return m, nil
+}
+
+// incrementalSettings checks the incremental tables configured on dst.
+func incrementalSettings(dst config.Source) (config.Incremental, error) {
+	if dst.Incremental == nil || len(dst.Incremental.Tables) == 0 {
+		return config.Incremental{}, fmt.Errorf("%s mode needs incremental.tables on %q", config.ModeIncremental, dst.Name)
+	}
+	inc := *dst.Incremental
+	for _, w := range inc.Tables {
+		if w.Table == "" || w.Column == "" {
+			return inc, fmt.Errorf("destination %q: every incremental table needs a table and a watermark column", dst.Name)
+		}
+	}
+	switch inc.State {
+	case "", config.StateInFile, config.StateInTable:
+	default:
+		return inc, fmt.Errorf("destination %q: unknown incremental state %q (want %q or %q)", dst.Name, inc.State, config.StateInFile, config.StateInTable)
+	}
+	return inc, nil
+}
+
+// lockDestination takes the advisory lock on dst so that no one else can refresh it
+// concurrently. With wait, it reports who holds the lock and blocks until it is free.
+func lockDestination(ctx context.Context, dst config.Source, wait bool) (*psql.DestLock, error) {
+	holder := "unknown"
+	if u, err := user.Current(); err == nil {
+		holder = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		holder += "@" + host
+	}
+	lock, err := psql.Lock(ctx, toConn(dst), holder, wait, func(le *psql.LockedError) {
+		pterm.Warning.Printfln("%s is being refreshed by %s since %s; waiting...", dst.Name, le.Holder, le.Since.Format("15:04"))
+	})
+	var le *psql.LockedError
+	if errors.As(err, &le) {
+		return nil, fmt.Errorf("%s is being refreshed by %s since %s (use --wait to queue behind it)", dst.Name, le.Holder, le.Since.Format("15:04"))
+	}
+	return lock, err
+}
+
+// checkPolicies evaluates the configured policies for a transfer in mode run by the
+// current OS user.
+func checkPolicies(c config.Config, src, dst, mode string) error {
+	u, err := user.Current()
+	if err != nil {
+		return err
+	}
+	return policy.Check(c.Policies, policy.Request{
+		Source: src, Destination: dst, Mode: mode,
+		User: u.Username, Now: time.Now(),
+	})
+}
+
+// checkWipeWindows checks the wipe windows of dst again once its lock is held, since
+// waiting for the confirmation or the lock may have taken the run outside them. Modes
+// that don't wipe pass.
+func checkWipeWindows(c config.Config, dst, mode string) error {
+	if !policy.Wipes(mode) {
+		return nil
+	}
+	return policy.CheckWipeWindows(c.Policies, dst, time.Now())
 }
 
 func toConn(s config.Source) psql.Conn {
-	return psql.Conn{
+	c := psql.Conn{
 		Host: s.Host, Port: s.Port,
 		User: s.User, Password: s.Password,
 		DBName: s.DBName, SSLMode: s.SSLMode,
 	}
+	if s.Server {
+		c.DBName = s.MaintenanceDB()
+	}
+	return c
 }
 
 // humanSize returns a human-friendly file size using binary units.
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jayps/psql-transporter/internal/config"
+)
+
+func TestMergeSettings(t *testing.T) {
+	configured := config.Source{Name: "dev", Merge: &config.Merge{Tables: []string{"countries", "billing.plans"}}}
+	tests := []struct {
+		name          string
+		dst           config.Source
+		tables        []string
+		deleteMissing bool
+		want          config.Merge
+		wantErr       string
+	}{
+		{
+			name: "from the destination",
+			dst:  configured,
+			want: config.Merge{Tables: []string{"countries", "billing.plans"}},
+		},
+		{
+			name:          "flags win",
+			dst:           configured,
+			tables:        []string{"currencies"},
+			deleteMissing: true,
+			want:          config.Merge{Tables: []string{"currencies"}, DeleteMissing: true},
+		},
+		{
+			name: "delete_missing from the destination",
+			dst:  config.Source{Name: "dev", Merge: &config.Merge{Tables: []string{"countries"}, DeleteMissing: true}},
+			want: config.Merge{Tables: []string{"countries"}, DeleteMissing: true},
+		},
+		{
+			name:   "flags without merge settings",
+			dst:    config.Source{Name: "dev"},
+			tables: []string{"countries"},
+			want:   config.Merge{Tables: []string{"countries"}},
+		},
+		{
+			name:    "no tables",
+			dst:     config.Source{Name: "dev"},
+			wantErr: `merge mode needs tables: pass --tables or set merge.tables on "dev"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeSettings(tt.dst, tt.tables, tt.deleteMissing)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if configured.Merge.DeleteMissing {
+		t.Error("mergeSettings changed the destination's merge settings")
+	}
+}
+
+func TestIncrementalSettings(t *testing.T) {
+	orders := config.Watermark{Table: "orders", Column: "updated_at"}
+	tests := []struct {
+		name    string
+		inc     *config.Incremental
+		wantErr string
+	}{
+		{name: "state in the file by default", inc: &config.Incremental{Tables: []config.Watermark{orders}}},
+		{name: "state in a table", inc: &config.Incremental{Tables: []config.Watermark{orders}, State: config.StateInTable}},
+		{name: "not configured", wantErr: `incremental mode needs incremental.tables on "dev"`},
+		{name: "no tables", inc: &config.Incremental{}, wantErr: `incremental mode needs incremental.tables on "dev"`},
+		{
+			name:    "no watermark column",
+			inc:     &config.Incremental{Tables: []config.Watermark{{Table: "orders"}}},
+			wantErr: `destination "dev": every incremental table needs a table and a watermark column`,
+		},
+		{
+			name:    "unknown state",
+			inc:     &config.Incremental{Tables: []config.Watermark{orders}, State: "redis"},
+			wantErr: `destination "dev": unknown incremental state "redis" (want "file" or "table")`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := incrementalSettings(config.Source{Name: "dev", Incremental: tt.inc})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, *tt.inc) {
+				t.Errorf("got %+v, want %+v", got, *tt.inc)
+			}
+		})
+	}
+}
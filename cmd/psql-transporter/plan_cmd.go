@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jayps/psql-transporter/internal/config"
+	"github.com/jayps/psql-transporter/internal/hooks"
+	"github.com/jayps/psql-transporter/internal/plan"
+	"github.com/jayps/psql-transporter/internal/psql"
+	"github.com/jayps/psql-transporter/internal/ui"
+)
+
+// defaultPlanFile is where plan writes unless told otherwise.
+const defaultPlanFile = "plan.json"
+
+func newPlanCmd() *cobra.Command {
+	var from, to, mode, intoSchema, out string
+	cmd := &cobra.Command{
+		Use:   "plan [recipe]",
+		Short: "Write a reviewable plan of a transfer without changing anything",
+		Long: `Resolves a transfer from a recipe or from --from and --to, checks policies and
+connections, and writes every step with its command line, target and estimated size
+to a JSON file. Apply it later with "apply".`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := loadConfig(filepath.Join(".", config.DefaultFile))
+			if err != nil {
+				return err
+			}
+			recipe := ""
+			if len(args) == 1 {
+				r, err := c.Recipe(args[0])
+				if err != nil {
+					return err
+				}
+				if err := r.Check(c); err != nil {
+					return err
+				}
+				if r.File != "" || len(r.To) != 1 || r.AllDatabases || len(r.Databases) > 0 {
+					return fmt.Errorf("recipe %q: plans cover one source database and one destination", r.Name)
+				}
+				c = r.Apply(c)
+				recipe, from, to, mode, intoSchema = r.Name, r.Source, r.To[0], r.Mode, r.IntoSchema
+			}
+			if from == "" || to == "" {
+				return errors.New("name a recipe, or pass --from and --to")
+			}
+			p := plan.Plan{Recipe: recipe, Mode: mode, IntoSchema: intoSchema}
+			ctx, cancel := psql.DefaultTimeoutCtx()
+			defer cancel()
+			src, dst, err := resolvePlan(ctx, c, &p, from, to)
+			if err != nil {
+				return err
+			}
+
+			p.Version = plan.Version
+			p.CreatedAt = time.Now().UTC().Truncate(time.Second)
+			if u, err := user.Current(); err == nil {
+				p.CreatedBy = u.Username
+			}
+			p.Steps = planSteps(c, *src, *dst, p)
+			if err := plan.Save(out, p); err != nil {
+				return err
+			}
+			if err := printPlan(p); err != nil {
+				return err
+			}
+			fmt.Printf("Plan written to %s. Nothing was changed; run \"psql-transporter apply %s\" to carry it out.\n", out, out)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "Source database")
+	cmd.Flags().StringVar(&to, "to", "", "Destination database")
+	cmd.Flags().StringVar(&mode, "mode", config.ModeFull, fmt.Sprintf("Transfer mode: %s or %s", config.ModeFull, config.ModeDataOnly))
+	cmd.Flags().StringVar(&intoSchema, "into-schema", "", "Copy the source's public schema into this schema instead")
+	cmd.Flags().StringVarP(&out, "output", "o", defaultPlanFile, "Plan file to write")
+	return cmd
+}
+
+func newApplyCmd() *cobra.Command {
+	var wait bool
+	cmd := &cobra.Command{
+		Use:   "apply <plan.json>",
+		Short: "Carry out a plan written by plan, if nothing changed since",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := plan.Load(args[0])
+			if err != nil {
+				return err
+			}
+			c, err := loadConfig(filepath.Join(".", config.DefaultFile))
+			if err != nil {
+				return err
+			}
+			if p.Recipe != "" {
+				r, err := c.Recipe(p.Recipe)
+				if err != nil {
+					return err
+				}
+				c = r.Apply(c)
+			}
+
+			ctx, cancel := psql.DefaultTimeoutCtx()
+			defer cancel()
+			ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			// Resolve the plan again and compare: the same settings, databases and schemas.
+			now := plan.Plan{Recipe: p.Recipe, Mode: p.Mode, IntoSchema: p.IntoSchema}
+			src, dst, err := resolvePlan(ctx, c, &now, p.Source.Name, p.Destination.Name)
+			if err != nil {
+				return err
+			}
+			if err := comparePlan(p, now); err != nil {
+				return fmt.Errorf("%w; make a new plan", err)
+			}
+
+			if err := printPlan(p); err != nil {
+				return err
+			}
+			t := &transfer{cfg: c, src: src, dst: dst, mode: p.Mode, intoSchema: p.IntoSchema}
+			if dst.Globals.Enabled {
+				if t.globals, err = planGlobals(ctx, *src, *dst); err != nil {
+					return err
+				}
+			}
+			ok, err := confirmWipe(c, *dst, fmt.Sprintf("Apply the plan made by %s at %s to DESTINATION %q?", p.CreatedBy, p.CreatedAt.Local().Format("2006-01-02 15:04"), dst.Name))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Println("Aborted.")
+				return nil
+			}
+			lock, err := lockDestination(ctx, *dst, wait)
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+			if err := checkWipeWindows(c, dst.Name, t.mode); err != nil {
+				return err
+			}
+			return t.run(ctx)
+		},
+	}
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for another run refreshing the destination to finish instead of failing")
+	return cmd
+}
+
+// resolvePlan checks that a plan from src to dst is allowed and fills in p's mode,
+// endpoints and settings digest from c and the live databases.
+func resolvePlan(ctx context.Context, c config.Config, p *plan.Plan, from, to string) (src, dst *config.Source, err error) {
+	if p.Mode, err = config.ParseMode(p.Mode); err != nil {
+		return nil, nil, err
+	}
+	if p.Mode != config.ModeFull && p.Mode != config.ModeDataOnly {
+		return nil, nil, fmt.Errorf("plans cover %s and %s refreshes, not %s", config.ModeFull, config.ModeDataOnly, p.Mode)
+	}
+	if p.IntoSchema == psql.WipeSchema {
+		p.IntoSchema = ""
+	}
+	if p.IntoSchema != "" {
+		if err := psql.CheckSchemaName(p.IntoSchema); err != nil {
+			return nil, nil, err
+		}
+		if p.Mode != config.ModeFull {
+			return nil, nil, fmt.Errorf("--into-schema works with mode %s only", config.ModeFull)
+		}
+	}
+	if src, err = sourceByName(c, from); err != nil {
+		return nil, nil, err
+	}
+	if dst, err = sourceByName(c, to); err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case src.Server || dst.Server:
+		return nil, nil, errors.New("plans cover single databases, not server-level sources")
+	case dst.Protected:
+		return nil, nil, fmt.Errorf("destination %q is protected; aborting", dst.Name)
+	case src.Name == dst.Name && p.IntoSchema == "":
+		return nil, nil, errors.New("source and destination cannot be the same")
+	}
+	if err := checkPolicies(c, src.Name, dst.Name, p.Mode); err != nil {
+		return nil, nil, err
+	}
+	if err := checkMaintenance(*dst); err != nil {
+		return nil, nil, err
+	}
+
+	// Everything the run reads from the config, without passwords.
+	settings := struct {
+		Hooks               config.Hooks
+		Source, Destination config.Source
+	}{c.Hooks, *src, *dst}
+	settings.Source.Password, settings.Destination.Password = "", ""
+	if p.Config, err = plan.Digest(settings); err != nil {
+		return nil, nil, err
+	}
+
+	schema := psql.WipeSchema
+	if p.IntoSchema != "" {
+		schema = p.IntoSchema
+	}
+	if p.Source, err = planEndpoint(ctx, *src, psql.WipeSchema); err != nil {
+		return nil, nil, fmt.Errorf("source %q: %w", src.Name, err)
+	}
+	if p.Destination, err = planEndpoint(ctx, *dst, schema); err != nil {
+		return nil, nil, fmt.Errorf("destination %q: %w", dst.Name, err)
+	}
+	return src, dst, nil
+}
+
+func planEndpoint(ctx context.Context, s config.Source, schema string) (plan.Endpoint, error) {
+	e := plan.Endpoint{Name: s.Name, Host: s.Host, Port: s.Port, DBName: s.DBName}
+	var err error
+	if e.Fingerprint, err = psql.Fingerprint(ctx, toConn(s)); err != nil {
+		return e, err
+	}
+	tables, err := psql.Tables(ctx, toConn(s), []string{schema})
+	for _, t := range tables {
+		e.Bytes += t.Bytes
+	}
+	return e, err
+}
+
+// comparePlan reports the first difference between a stored plan and the same plan
+// resolved now.
+func comparePlan(p, now plan.Plan) error {
+	for _, e := range []struct {
+		role    string
+		was, is plan.Endpoint
+	}{{"source", p.Source, now.Source}, {"destination", p.Destination, now.Destination}} {
+		switch {
+		case e.was.Host != e.is.Host || e.was.Port != e.is.Port || e.was.DBName != e.is.DBName:
+			return fmt.Errorf("%s %q now points at %s:%d/%s instead of %s:%d/%s", e.role, e.was.Name,
+				e.is.Host, e.is.Port, e.is.DBName, e.was.Host, e.was.Port, e.was.DBName)
+		case e.was.Fingerprint != e.is.Fingerprint:
+			return fmt.Errorf("%s %q changed since the plan was made (database recreated, server upgraded or schema changed)", e.role, e.was.Name)
+		}
+	}
+	if p.Config != now.Config {
+		return errors.New("the config for this transfer changed since the plan was made")
+	}
+	return nil
+}
+
+// planSteps lists what transfer.run does for p, in order.
+func planSteps(c config.Config, src, dst config.Source, p plan.Plan) []plan.Step {
+	srcConn, dstConn := toConn(src), toConn(dst)
+	dump := filepath.Join(".", "dump.sql")
+	var steps []plan.Step
+	add := func(name, command, target string, bytes int64) {
+		steps = append(steps, plan.Step{Name: name, Command: command, Target: target, Bytes: bytes})
+	}
+	hookSteps := func(phase string) {
+		for _, h := range hooks.Select(phase, c.Hooks, src.Hooks, dst.Hooks) {
+			add("Hook "+phase, h.Run, "", 0)
+		}
+	}
+	schema := psql.WipeSchema
+	if p.IntoSchema != "" {
+		schema = p.IntoSchema
+	}
+	target := func(s config.Source, name string) string { return s.Name + ":" + name }
+
+	add("Lock", "", dst.Name, 0)
+	if dst.Globals.Enabled {
+		add("Globals", psql.CommandLine(srcConn, "pg_dumpall", psql.GlobalsArgs(srcConn, dst.Globals.Passwords)), dst.Name+" roles", 0)
+	}
+	dumpOpts, importOpts := psql.DumpOptions{}, psql.ImportOptions{}
+	if p.Mode == config.ModeDataOnly {
+		add("Schema check", "", target(dst, psql.WipeSchema), 0)
+		dumpOpts = psql.DumpOptions{DataOnly: true, Schemas: []string{psql.WipeSchema}}
+		importOpts = psql.ImportOptions{ReplicaRole: true, StopOnError: true}
+	}
+	if p.IntoSchema != "" {
+		dumpOpts.Schemas = []string{psql.WipeSchema}
+	}
+	if p.Mode == config.ModeFull {
+		dumpOpts.KeepOwners = dst.KeepOwners
+	}
+
+	hookSteps(hooks.BeforeExport)
+	add("Export", psql.CommandLine(srcConn, "pg_dump", psql.DumpArgs(srcConn, dump, dumpOpts)), target(src, psql.WipeSchema), p.Source.Bytes)
+	if p.IntoSchema != "" {
+		add("Rewrite dump", "", fmt.Sprintf("%s → %s", psql.WipeSchema, p.IntoSchema), 0)
+	}
+	if dumpOpts.KeepOwners && len(dst.RoleMap) > 0 {
+		add("Remap roles", "", fmt.Sprint(dst.RoleMap), 0)
+	}
+	hookSteps(hooks.AfterExport)
+
+	full := p.IntoSchema == "" // the into-schema branch skips the public schema's settings
+	if full {
+		for _, k := range dst.KeepTables {
+			add("Save kept table", "", target(dst, k.Table), 0)
+		}
+	}
+	hookSteps(hooks.BeforeWipe)
+	if p.Mode == config.ModeDataOnly {
+		add("Truncate", "", target(dst, psql.WipeSchema), p.Destination.Bytes)
+	} else {
+		add("Wipe", psql.CommandLine(dstConn, "psql", psql.WipeArgs(dstConn, schema)), target(dst, schema), p.Destination.Bytes)
+	}
+	add("Import", psql.ImportCommandLine(dstConn, dump, importOpts), target(dst, schema), p.Source.Bytes)
+	if full {
+		if dst.Migrate != nil && dst.Migrate.Command != "" {
+			add("Migrations", dst.Migrate.Command, dst.Name, 0)
+		}
+		for _, k := range dst.KeepTables {
+			add("Restore kept table", "", target(dst, k.Table), 0)
+		}
+	}
+	if p.Mode == config.ModeFull {
+		if dst.Owner != "" {
+			add("Owner", "", fmt.Sprintf("%s → %s", target(dst, schema), dst.Owner), 0)
+		}
+		roles := make([]string, 0, len(dst.Grants))
+		for role := range dst.Grants {
+			roles = append(roles, role)
+		}
+		slices.Sort(roles)
+		for _, role := range roles {
+			add("Grant", "", fmt.Sprintf("%s to %s", target(dst, schema), role), 0)
+		}
+	}
+	if full {
+		for _, s := range dst.PostImport {
+			if s.File != "" {
+				add("Post-import SQL", s.File, dst.Name, 0)
+			} else {
+				add("Post-import SQL", oneLine(s.SQL), dst.Name, 0)
+			}
+		}
+		for _, task := range psql.MaintenanceTasks {
+			if slices.Contains(dst.Maintenance, task) {
+				add("Maintenance", task, dst.Name, 0)
+			}
+		}
+		for _, a := range dst.Assert {
+			add("Assertion", oneLine(a), dst.Name, 0)
+		}
+	}
+	hookSteps(hooks.AfterImport)
+	return steps
+}
+
+func printPlan(p plan.Plan) error {
+	fmt.Printf("Plan: %s %s → %s", p.Mode, p.Source.Name, p.Destination.Name)
+	if p.IntoSchema != "" {
+		fmt.Printf(" (into schema %s)", p.IntoSchema)
+	}
+	fmt.Println()
+	rows := make([][]string, len(p.Steps))
+	for i, s := range p.Steps {
+		size := ""
+		if s.Bytes > 0 {
+			size = humanSize(s.Bytes)
+		}
+		rows[i] = []string{fmt.Sprint(i + 1), s.Name, s.Target, size, s.Command}
+	}
+	return ui.Table([]string{"#", "Step", "Target", "Size", "Command"}, rows)
+}
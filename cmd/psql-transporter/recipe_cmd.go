@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jayps/psql-transporter/internal/config"
+	"github.com/jayps/psql-transporter/internal/ui"
+)
+
+func newRunCmd() *cobra.Command {
+	var wait bool
+	cmd := &cobra.Command{
+		Use:   "run [recipe]",
+		Short: "Run a recipe from the config, or list the recipes",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := loadConfig(filepath.Join(".", config.DefaultFile))
+			if err != nil {
+				return err
+			}
+			if len(args) == 0 {
+				return listRecipes(c)
+			}
+			r, err := c.Recipe(args[0])
+			if err != nil {
+				return err
+			}
+			r.Wait = r.Wait || wait
+			return runRecipe(c, r)
+		},
+	}
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for another run refreshing a destination to finish instead of failing")
+	return cmd
+}
+
+// runRecipe runs the transfer r describes. Only the confirmation is asked for.
+func runRecipe(c config.Config, r config.Recipe) error {
+	if err := r.Check(c); err != nil {
+		return err
+	}
+	fmt.Printf("Recipe %s: %s → %s\n", r.Name, recipeSource(r), strings.Join(r.To, ", "))
+	return runTransfer(context.Background(), r.Apply(c), recipeOptions(r))
+}
+
+// recipeOptions turns r into the options of the transfer it runs.
+func recipeOptions(r config.Recipe) transferOptions {
+	o := transferOptions{
+		source:        r.Source,
+		file:          r.File,
+		to:            r.To,
+		mode:          r.Mode,
+		mergeTables:   r.Tables,
+		deleteMissing: r.DeleteMissing,
+		full:          r.Full,
+		intoSchema:    r.IntoSchema,
+		allDatabases:  r.AllDatabases,
+		databases:     r.Databases,
+		parallel:      r.Parallel,
+		wait:          r.Wait,
+	}
+	if o.parallel == 0 {
+		o.parallel = 2
+	}
+	return o
+}
+
+func recipeSource(r config.Recipe) string {
+	if r.File != "" {
+		return r.File
+	}
+	return r.Source
+}
+
+// pickRecipe offers the configured recipes ahead of a custom transfer. ok is false
+// when the user picks the custom transfer.
+func pickRecipe(c config.Config) (r config.Recipe, ok bool, err error) {
+	const custom = "Custom transfer..."
+	names := make([]string, 0, len(c.Recipes)+1)
+	for _, r := range c.Recipes {
+		names = append(names, r.Name)
+	}
+	names = append(names, custom)
+	sel, err := ui.SelectTagged("Select RECIPE:", names, func(name string) string {
+		if r, err := c.Recipe(name); err == nil {
+			if r.Description != "" {
+				return r.Description
+			}
+			return recipeSource(r) + " → " + strings.Join(r.To, ", ")
+		}
+		return ""
+	})
+	if err != nil || sel == custom {
+		return r, false, err
+	}
+	r, err = c.Recipe(sel)
+	return r, err == nil, err
+}
+
+func listRecipes(c config.Config) error {
+	if len(c.Recipes) == 0 {
+		fmt.Println("No recipes in the config.")
+		return nil
+	}
+	rows := make([][]string, len(c.Recipes))
+	for i, r := range c.Recipes {
+		mode := r.Mode
+		if mode == "" {
+			mode = config.ModeFull
+		}
+		rows[i] = []string{r.Name, recipeSource(r), strings.Join(r.To, ", "), mode, r.Description}
+	}
+	return ui.Table([]string{"Recipe", "From", "To", "Mode", "Description"}, rows)
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+
+	"github.com/jayps/psql-transporter/internal/config"
+	"github.com/jayps/psql-transporter/internal/psql"
+	"github.com/jayps/psql-transporter/internal/ui"
+)
+
+func newReplicateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "replicate",
+		Short: "Keep a destination in sync with a source using logical replication",
+	}
+	cmd.AddCommand(newReplicateStartCmd(), newReplicateStatusCmd(), newReplicateStopCmd())
+	return cmd
+}
+
+func newReplicateStartCmd() *cobra.Command {
+	var (
+		to, conninfo string
+		wait         bool
+	)
+	cmd := &cobra.Command{
+		Use:   "start <source> --to <destination>",
+		Short: "Copy the schema and start streaming changes into a destination",
+		Long: "Wipe the destination and copy the source's schema into it, then publish the\n" +
+			"source's tables and subscribe the destination to them. The subscription copies\n" +
+			"the existing rows and then follows every change.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := loadConfig(filepath.Join(".", config.DefaultFile))
+			if err != nil {
+				return err
+			}
+			src, err := sourceByName(c, args[0])
+			if err != nil {
+				return err
+			}
+			dst, err := sourceByName(c, to)
+			if err != nil {
+				return err
+			}
+			if dst.Protected {
+				return fmt.Errorf("destination %q is protected; aborting", dst.Name)
+			}
+			if src.Name == dst.Name {
+				return fmt.Errorf("source and destination cannot be the same")
+			}
+			if err := checkPolicies(c, src.Name, dst.Name, config.ModeFull); err != nil {
+				return err
+			}
+			if conninfo == "" {
+				conninfo = toConn(*src).URL()
+			}
+
+			ctx, cancel := psql.DefaultTimeoutCtx()
+			defer cancel()
+			ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			// Refuse before anything is changed.
+			name := psql.ReplicationName(dst.Name)
+			sub, err := psql.FindSubscription(ctx, toConn(*dst), name)
+			if err != nil {
+				return err
+			}
+			if sub != nil {
+				return fmt.Errorf("%s already has subscription %s; run `replicate stop %s --to %s` first", dst.Name, name, src.Name, dst.Name)
+			}
+			blocked, err := psql.TablesWithoutReplicaIdentity(ctx, toConn(*src), []string{psql.WipeSchema})
+			if err != nil {
+				return err
+			}
+			if len(blocked) > 0 {
+				return fmt.Errorf("these tables have no primary key or replica identity; once published, UPDATE and DELETE on them would fail on %q:\n  %s",
+					src.Name, strings.Join(blocked, "\n  "))
+			}
+			tables, err := sourceTables(ctx, *src)
+			if err != nil {
+				return err
+			}
+			if len(tables) == 0 {
+				return fmt.Errorf("%q has no tables in schema %s to replicate", src.Name, psql.WipeSchema)
+			}
+
+			showImpact(ctx, *dst, psql.WipeSchema, func() ([]string, error) { return tables, nil })
+			ok, err := confirmWipe(c, *dst, fmt.Sprintf("DESTINATION %q will be WIPED and then follow every change to %q. Continue?", dst.Name, src.Name))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Println("Aborted.")
+				return nil
+			}
+			lock, err := lockDestination(ctx, *dst, wait)
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+			if err := checkWipeWindows(c, dst.Name, config.ModeFull); err != nil {
+				return err
+			}
+
+			schema, err := os.CreateTemp("", "psql-transporter-schema-*.sql")
+			if err != nil {
+				return err
+			}
+			schema.Close()
+			defer os.Remove(schema.Name())
+			if err := export(ctx, *src, schema.Name(), psql.DumpOptions{SchemaOnly: true}); err != nil {
+				return err
+			}
+			if err := ui.RunSteps([]ui.Step{
+				{Title: "Wiping destination...", Run: func() error { return psql.Wipe(ctx, toConn(*dst)) }},
+			}); err != nil {
+				return err
+			}
+			if err := importDump(ctx, *dst, schema.Name(), psql.ImportOptions{}); err != nil {
+				return err
+			}
+			err = ui.RunSteps([]ui.Step{
+				{
+					Title: fmt.Sprintf("Publishing %d tables on %s...", len(tables), src.Name),
+					Run:   func() error { return psql.CreatePublication(ctx, toConn(*src), name, tables) },
+				},
+				{
+					Title: fmt.Sprintf("Subscribing %s...", dst.Name),
+					Run: func() error {
+						err := psql.CreateSubscription(ctx, toConn(*dst), name, conninfo)
+						if err != nil {
+							// Don't leave a publication (or a slot, if one was made) behind.
+							psql.DropReplicationSlots(context.WithoutCancel(ctx), toConn(*src), name, "")
+							psql.DropPublication(context.WithoutCancel(ctx), toConn(*src), name)
+						}
+						return err
+					},
+				},
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s now replicates into %s. Follow the initial copy with:\n  psql-transporter replicate status %s --to %s\n",
+				src.Name, dst.Name, src.Name, dst.Name)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&to, "to", "", "Destination database")
+	cmd.Flags().StringVar(&conninfo, "source-conninfo", "", "Connection string the destination server uses to reach the source (default: the source's settings)")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for another run refreshing the destination to finish instead of failing")
+	cmd.MarkFlagRequired("to")
+	return cmd
+}
+
+func newReplicateStatusCmd() *cobra.Command {
+	var to string
+	cmd := &cobra.Command{
+		Use:   "status <source>",
+		Short: "Show replication slots, lag and table sync states",
+		Long: "List the replication slots this tool created on the source, with their lag and\n" +
+			"retained WAL, and the state of each table on every destination replicating from\n" +
+			"it (or only the one given with --to). Inactive slots are flagged: they keep WAL\n" +
+			"on the source until they are dropped.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := loadConfig(filepath.Join(".", config.DefaultFile))
+			if err != nil {
+				return err
+			}
+			src, err := sourceByName(c, args[0])
+			if err != nil {
+				return err
+			}
+			ctx, cancel := psql.DefaultTimeoutCtx()
+			defer cancel()
+
+			slots, err := psql.ReplicationSlots(ctx, toConn(*src))
+			if err != nil {
+				return err
+			}
+			slotDest := make(map[string]string) // slot name -> configured destination
+			var dests []config.Source
+			for _, d := range c.Sources {
+				if d.Name == src.Name {
+					continue
+				}
+				name := psql.ReplicationName(d.Name)
+				slotDest[name] = d.Name
+				if to != "" && d.Name != to {
+					continue
+				}
+				if to != "" || slices.ContainsFunc(slots, func(s psql.ReplicationSlot) bool { return s.Name == name }) {
+					dests = append(dests, d)
+				}
+			}
+			if to != "" && len(dests) == 0 {
+				return fmt.Errorf("no database named %q in the config", to)
+			}
+			if len(slots) == 0 && to == "" {
+				fmt.Printf("Nothing is replicating from %s.\n", src.Name)
+				return nil
+			}
+
+			lag := make(map[string]int64)
+			if len(slots) > 0 {
+				rows := make([][]string, 0, len(slots))
+				for _, s := range slots {
+					dest, active := slotDest[s.Name], pterm.FgGreen.Sprint("active")
+					if strings.HasPrefix(s.Name, "pg_") {
+						dest = "(table sync)"
+					}
+					if !s.Active {
+						active = pterm.FgRed.Sprint("INACTIVE")
+					}
+					lag[s.Name] = s.Unflushed
+					rows = append(rows, []string{s.Name, dest, active, humanSize(s.Unflushed), humanSize(s.Retained)})
+				}
+				fmt.Printf("Replication slots on %s:\n", src.Name)
+				ui.Table([]string{"Slot", "Destination", "Status", "Lag", "Retained WAL"}, rows)
+				for _, s := range slots {
+					if s.Active {
+						continue
+					}
+					hint := fmt.Sprintf("replicate stop %s --to %s", src.Name, slotDest[s.Name])
+					if slotDest[s.Name] == "" {
+						hint = fmt.Sprintf("replicate stop %s --slot %s", src.Name, s.Name)
+					}
+					pterm.Warning.Printfln("slot %s is inactive and keeps %s of WAL on %s; if nothing replicates through it anymore, run `%s`",
+						s.Name, humanSize(s.Retained), src.Name, hint)
+				}
+			}
+
+			for _, d := range dests {
+				name := psql.ReplicationName(d.Name)
+				sub, err := psql.FindSubscription(ctx, toConn(d), name)
+				if err != nil {
+					pterm.Warning.Printfln("%s: couldn't read subscription %s: %v", d.Name, name, err)
+					continue
+				}
+				fmt.Println()
+				if sub == nil {
+					pterm.Warning.Printfln("%s has no subscription %s", d.Name, name)
+					continue
+				}
+				state := "running"
+				switch {
+				case !sub.Enabled:
+					state = pterm.FgRed.Sprint("disabled")
+				case !sub.Running:
+					state = pterm.FgRed.Sprint("not running")
+				}
+				if sub.LastMessage != "" {
+					state += ", last message " + sub.LastMessage + " ago"
+				}
+				fmt.Printf("%s (%s):\n", d.Name, state)
+				slotLag, hasSlot := lag[name]
+				rows := make([][]string, 0, len(sub.Tables))
+				for _, t := range sub.Tables {
+					tableLag := "-"
+					if t.State == "ready" && hasSlot {
+						tableLag = humanSize(slotLag)
+					}
+					rows = append(rows, []string{t.Table, t.State, tableLag})
+				}
+				ui.Table([]string{"Table", "State", "Lag"}, rows)
+				if !hasSlot {
+					pterm.Warning.Printfln("slot %s is missing on %s; the subscription can't receive changes", name, src.Name)
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&to, "to", "", "Only show this destination")
+	return cmd
+}
+
+func newReplicateStopCmd() *cobra.Command {
+	var to, slot string
+	cmd := &cobra.Command{
+		Use:   "stop <source> --to <destination>",
+		Short: "Stop replicating and drop the subscription, slots and publication",
+		Long: "Drop the destination's subscription, then its replication slots and the\n" +
+			"publication on the source. Each step runs even if an earlier one failed, so a\n" +
+			"destination that is gone doesn't keep a slot holding WAL on the source. Use\n" +
+			"--slot to drop a leftover slot whose destination is no longer in the config.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (to == "") == (slot == "") {
+				return errors.New("pass either --to or --slot")
+			}
+			c, err := loadConfig(filepath.Join(".", config.DefaultFile))
+			if err != nil {
+				return err
+			}
+			src, err := sourceByName(c, args[0])
+			if err != nil {
+				return err
+			}
+			ctx, cancel := psql.DefaultTimeoutCtx()
+			defer cancel()
+
+			name, subOID := slot, ""
+			var problems []string
+			if to != "" {
+				name = psql.ReplicationName(to)
+				if dst, err := sourceByName(c, to); err != nil {
+					pterm.Warning.Printfln("%v; only cleaning up %s", err, src.Name)
+				} else {
+					subOID, err = ui.StepSpinner(fmt.Sprintf("Dropping subscription %s on %s...", name, dst.Name), func() (string, error) {
+						return psql.DropSubscription(ctx, toConn(*dst), name)
+					})
+					if err != nil {
+						problems = append(problems, fmt.Sprintf("subscription %s on %s: %v (drop it with ALTER SUBSCRIPTION %[1]s DISABLE; ALTER SUBSCRIPTION %[1]s SET (slot_name = NONE); DROP SUBSCRIPTION %[1]s;)",
+							name, dst.Name, err))
+					}
+				}
+			}
+
+			dropped, err := ui.StepSpinner(fmt.Sprintf("Dropping replication slots on %s...", src.Name), func() ([]string, error) {
+				return psql.DropReplicationSlots(ctx, toConn(*src), name, subOID)
+			})
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("slot %s on %s: %v (drop it with SELECT pg_drop_replication_slot('%[1]s');)", name, src.Name, err))
+			}
+			if _, err := ui.StepSpinner(fmt.Sprintf("Dropping publication %s on %s...", name, src.Name), func() (any, error) {
+				return nil, psql.DropPublication(ctx, toConn(*src), name)
+			}); err != nil {
+				problems = append(problems, fmt.Sprintf("publication %s on %s: %v", name, src.Name, err))
+			}
+			if len(dropped) > 0 {
+				fmt.Printf("Dropped slot(s): %s\n", strings.Join(dropped, ", "))
+			}
+
+			// Table sync slots can only be matched through the subscription; point out any we couldn't.
+			if subOID == "" {
+				if slots, err := psql.ReplicationSlots(ctx, toConn(*src)); err == nil {
+					for _, s := range slots {
+						if strings.HasPrefix(s.Name, "pg_") && !s.Active {
+							pterm.Warning.Printfln("table sync slot %s on %s is inactive; if it belonged to this subscription, run `replicate stop %s --slot %s`",
+								s.Name, src.Name, src.Name, s.Name)
+						}
+					}
+				}
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("replication was not fully cleaned up:\n  %s", strings.Join(problems, "\n  "))
+			}
+			fmt.Println("Replication stopped.")
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&to, "to", "", "Destination database")
+	cmd.Flags().StringVar(&slot, "slot", "", "Drop this leftover slot and its publication instead of a configured destination's")
+	return cmd
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jayps/psql-transporter/internal/config"
+	"github.com/jayps/psql-transporter/internal/policy"
+	"github.com/jayps/psql-transporter/internal/psql"
+	"github.com/jayps/psql-transporter/internal/ui"
+)
+
+func newSchemaCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "List and drop schemas, such as those made with --into-schema",
+	}
+	cmd.AddCommand(newSchemaListCmd(), newSchemaDropCmd())
+	return cmd
+}
+
+func newSchemaListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list <database>",
+		Short: "List the schemas of a database with their table count and size",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := loadConfig(filepath.Join(".", config.DefaultFile))
+			if err != nil {
+				return err
+			}
+			db, err := sourceByName(c, args[0])
+			if err != nil {
+				return err
+			}
+			ctx, cancel := psql.DefaultTimeoutCtx()
+			defer cancel()
+
+			schemas, err := psql.Schemas(ctx, toConn(*db))
+			if err != nil {
+				return err
+			}
+			rows := make([][]string, len(schemas))
+			for i, s := range schemas {
+				rows[i] = []string{s.Name, fmt.Sprint(s.Tables), humanSize(s.Bytes)}
+			}
+			return ui.Table([]string{"Schema", "Tables", "Size"}, rows)
+		},
+	}
+}
+
+func newSchemaDropCmd() *cobra.Command {
+	var wait bool
+	cmd := &cobra.Command{
+		Use:   "drop <database> <schema>",
+		Short: "Drop a schema and everything in it",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			schema := args[1]
+			if schema == psql.WipeSchema {
+				return fmt.Errorf("refusing to drop schema %s; a normal transfer replaces it", psql.WipeSchema)
+			}
+			c, err := loadConfig(filepath.Join(".", config.DefaultFile))
+			if err != nil {
+				return err
+			}
+			db, err := sourceByName(c, args[0])
+			if err != nil {
+				return err
+			}
+			if db.Protected {
+				return fmt.Errorf("database %q is protected; aborting", db.Name)
+			}
+			// Dropping a schema wipes part of the database, so it passes the same
+			// policies as loading a dump file into it.
+			if err := checkPolicies(c, policy.File, db.Name, config.ModeFull); err != nil {
+				return err
+			}
+			ctx, cancel := psql.DefaultTimeoutCtx()
+			defer cancel()
+
+			schemas, err := psql.Schemas(ctx, toConn(*db))
+			if err != nil {
+				return err
+			}
+			i := slices.IndexFunc(schemas, func(s psql.SchemaInfo) bool { return s.Name == schema })
+			if i < 0 {
+				return fmt.Errorf("%q has no schema %q", db.Name, schema)
+			}
+			s := schemas[i]
+			ok, err := confirmWipe(c, *db, fmt.Sprintf("Schema %q in %q (%d tables, %s) will be DROPPED. Continue?", s.Name, db.Name, s.Tables, humanSize(s.Bytes)))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Println("Aborted.")
+				return nil
+			}
+			lock, err := lockDestination(ctx, *db, wait)
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+			if err := checkWipeWindows(c, db.Name, config.ModeFull); err != nil {
+				return err
+			}
+
+			return ui.RunSteps([]ui.Step{{
+				Title: fmt.Sprintf("Dropping schema %s...", s.Name),
+				Run:   func() error { return psql.DropSchema(ctx, toConn(*db), s.Name) },
+			}})
+		},
+	}
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for another run refreshing the database to finish instead of failing")
+	return cmd
+}
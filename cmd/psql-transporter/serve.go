@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+
+	"github.com/jayps/psql-transporter/internal/config"
+	"github.com/jayps/psql-transporter/internal/policy"
+)
+
+//go:embed web
+var webFiles embed.FS
+
+// defaultServeAddr is where serve listens unless told otherwise: this machine only.
+const defaultServeAddr = "127.0.0.1:8080"
+
+// tokenEnv holds the API token when --token is not given.
+const tokenEnv = "PSQL_TRANSPORTER_TOKEN"
+
+// maxRuns is how many runs the server remembers.
+const maxRuns = 50
+
+func newServeCmd() *cobra.Command {
+	var addr, token string
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve an HTTP API and web UI for running transfers",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := filepath.Join(".", config.DefaultFile)
+			c, err := loadConfig(path)
+			if err != nil {
+				return err
+			}
+			if err := checkServable(c); err != nil {
+				return err
+			}
+			if token == "" {
+				token = os.Getenv(tokenEnv)
+			}
+			if token == "" {
+				b := make([]byte, 16)
+				rand.Read(b)
+				token = hex.EncodeToString(b)
+			}
+			exe, err := os.Executable()
+			if err != nil {
+				return err
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return serve(ctx, &server{cfgPath: path, token: token, exe: exe, ctx: ctx}, addr)
+		},
+	}
+	cmd.Flags().StringVar(&addr, "addr", defaultServeAddr, "Address to listen on")
+	cmd.Flags().StringVar(&token, "token", "", "Token API clients must send (default: $"+tokenEnv+", or a random one)")
+	return cmd
+}
+
+// checkServable refuses configs serve can't enforce: policies.users rules name OS
+// users, but every run started over the API runs as the user who started serve.
+func checkServable(c config.Config) error {
+	if len(c.Policies.Users) > 0 {
+		return errors.New("serve can't enforce policies.users: every API client would run transfers as the user who started serve; remove the rules or run transfers from the command line")
+	}
+	return nil
+}
+
+// server runs transfers asked for over HTTP, one at a time, each in a worker process
+// of its own.
+type server struct {
+	cfgPath string
+	token   string
+	exe     string          // this executable, run as the worker
+	ctx     context.Context // cancelled on shutdown, which cancels the active run
+
+	mu     sync.Mutex
+	runs   []*serveRun // oldest first
+	active *serveRun
+	lastID int
+}
+
+// serve listens on addr until ctx is done, then cancels the active run and waits for
+// it to end.
+func serve(ctx context.Context, s *server, addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("--addr: %w", err)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		pterm.Warning.Printfln("Listening on %s: anyone who can reach it and has the token can refresh databases. Put TLS in front of it.", addr)
+	}
+	srv := &http.Server{Handler: s.routes(), ReadHeaderTimeout: 10 * time.Second}
+	fmt.Printf("Serving on http://%s/#token=%s\n", ln.Addr(), s.token)
+	fmt.Println("Stop with Ctrl-C.")
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	s.mu.Lock()
+	active := s.active
+	s.mu.Unlock()
+	if active != nil {
+		fmt.Printf("Stopping; cancelling %s and waiting for it to clean up...\n", active.Info().Title)
+		<-active.done
+	}
+	sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return srv.Shutdown(sctx)
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	web, _ := fs.Sub(webFiles, "web")
+	mux.Handle("GET /", http.FileServerFS(web))
+	mux.Handle("GET /api/sources", s.auth(s.handleSources))
+	mux.Handle("GET /api/recipes", s.auth(s.handleRecipes))
+	mux.Handle("POST /api/preview", s.auth(s.handlePreview))
+	mux.Handle("GET /api/runs", s.auth(s.handleRuns))
+	mux.Handle("POST /api/runs", s.auth(s.handleStart))
+	mux.Handle("GET /api/runs/{id}", s.auth(s.handleRun))
+	mux.Handle("GET /api/runs/{id}/log", s.auth(s.handleLog))
+	mux.Handle("GET /api/runs/{id}/events", s.auth(s.handleEvents))
+	mux.Handle("POST /api/runs/{id}/cancel", s.auth(s.handleCancel))
+	return mux
+}
+
+// auth lets a request through if it carries the token, as a bearer token or, for
+// GET requests such as an EventSource, as the token query parameter.
+func (s *server) auth(h http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok && r.Method == http.MethodGet {
+			token = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			httpError(w, http.StatusUnauthorized, errors.New("missing or wrong token"))
+			return
+		}
+		h(w, r)
+	})
+}
+
+// sourceInfo is a configured database as the API lists it. Passwords stay out.
+type sourceInfo struct {
+	Name        string `json:"name"`
+	Environment string `json:"environment,omitempty"`
+	Color       string `json:"color,omitempty"`
+	Host        string `json:"host"`
+	DBName      string `json:"dbname"`
+	Server      bool   `json:"server"`
+	Protected   bool   `json:"protected"`
+	Typed       bool   `json:"typed"` // must be confirmed by typing its name
+}
+
+func (s *server) handleSources(w http.ResponseWriter, r *http.Request) {
+	c, err := config.Load(s.cfgPath)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	out := make([]sourceInfo, len(c.Sources))
+	for i, src := range c.Sources {
+		typed, err := c.NeedsTypedConfirmation(src)
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, err)
+			return
+		}
+		out[i] = sourceInfo{
+			Name: src.Name, Environment: src.Environment, Color: c.ColorOf(src),
+			Host: src.Host, DBName: src.DBName,
+			Server: src.Server, Protected: src.Protected, Typed: typed,
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// recipeInfo is a recipe as the API lists it.
+type recipeInfo struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Source      string   `json:"source,omitempty"`
+	File        string   `json:"file,omitempty"`
+	To          []string `json:"to"`
+	Mode        string   `json:"mode"`
+}
+
+func (s *server) handleRecipes(w http.ResponseWriter, r *http.Request) {
+	c, err := config.Load(s.cfgPath)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	out := make([]recipeInfo, len(c.Recipes))
+	for i, rec := range c.Recipes {
+		mode, _ := config.ParseMode(rec.Mode)
+		out[i] = recipeInfo{Name: rec.Name, Description: rec.Description, Source: rec.Source, File: rec.File, To: rec.To, Mode: mode}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) handlePreview(w http.ResponseWriter, r *http.Request) {
+	req, c, ok := s.decode(w, r)
+	if !ok {
+		return
+	}
+	p, err := previewRun(r.Context(), c, req)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// handleStart starts the transfer asked for once it passes the checks the interactive
+// flow makes before asking: it must be a valid transfer, allowed by the policies and
+// confirmed for every destination. The run itself checks all of that again.
+func (s *server) handleStart(w http.ResponseWriter, r *http.Request) {
+	req, c, ok := s.decode(w, r)
+	if !ok {
+		return
+	}
+	c, o, err := req.resolve(c)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	for _, name := range o.to {
+		if !slices.Contains(req.Confirm, name) {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("destination %q is not confirmed; list its name in confirm", name))
+			return
+		}
+		policySrc := o.source
+		if o.file != "" {
+			policySrc = policy.File
+		}
+		if err := checkPolicies(c, policySrc, name, o.mode); err != nil {
+			httpError(w, http.StatusForbidden, err)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active != nil {
+		httpError(w, http.StatusConflict, fmt.Errorf("%s is still running; wait for it or cancel it", s.active.Info().Title))
+		return
+	}
+	run, err := startRun(s.ctx, s.exe, strconv.Itoa(s.lastID+1), req)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.lastID++
+	s.active = run
+	s.runs = append(s.runs, run)
+	if len(s.runs) > maxRuns {
+		s.runs = slices.Delete(s.runs, 0, len(s.runs)-maxRuns)
+	}
+	go func() {
+		<-run.done
+		s.mu.Lock()
+		s.active = nil
+		s.mu.Unlock()
+	}()
+	writeJSON(w, http.StatusCreated, run.Info())
+}
+
+// decode reads the run request in r's body and loads the config, answering with an
+// error if either fails or the config was changed into one serve can't enforce.
+func (s *server) decode(w http.ResponseWriter, r *http.Request) (runRequest, config.Config, bool) {
+	var req runRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("reading the request: %w", err))
+		return req, config.Config{}, false
+	}
+	c, err := config.Load(s.cfgPath)
+	if err == nil {
+		err = checkServable(c)
+	}
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return req, c, false
+	}
+	return req, c, true
+}
+
+// handleRuns lists the runs, newest first.
+func (s *server) handleRuns(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	out := make([]runInfo, len(s.runs))
+	for i, run := range s.runs {
+		out[len(s.runs)-1-i] = run.Info()
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) handleRun(w http.ResponseWriter, r *http.Request) {
+	if run := s.run(w, r); run != nil {
+		writeJSON(w, http.StatusOK, run.Info())
+	}
+}
+
+// handleLog returns the output of a run so far as plain text.
+func (s *server) handleLog(w http.ResponseWriter, r *http.Request) {
+	run := s.run(w, r)
+	if run == nil {
+		return
+	}
+	lines, _, _ := run.since(0)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+}
+
+// handleEvents streams the output of a run as server-sent events: a "log" event per
+// line, from the first, and an "end" event with the run's info once it is over.
+func (s *server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	run := s.run(w, r)
+	if run == nil {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httpError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	sent := 0
+	for {
+		lines, info, changed := run.since(sent)
+		for _, line := range lines {
+			fmt.Fprintf(w, "event: log\ndata: %s\n\n", line)
+		}
+		sent += len(lines)
+		if info.Status != config.RunRunning {
+			b, _ := json.Marshal(info)
+			fmt.Fprintf(w, "event: end\ndata: %s\n\n", b)
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	run := s.run(w, r)
+	if run == nil {
+		return
+	}
+	if run.Info().Status != config.RunRunning {
+		httpError(w, http.StatusConflict, errors.New("the run is over"))
+		return
+	}
+	run.cancel()
+	writeJSON(w, http.StatusAccepted, run.Info())
+}
+
+// run finds the run named in the request path, answering 404 if there is none.
+func (s *server) run(w http.ResponseWriter, r *http.Request) *serveRun {
+	id := r.PathValue("id")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, run := range s.runs {
+		if run.Info().ID == id {
+			return run
+		}
+	}
+	httpError(w, http.StatusNotFound, fmt.Errorf("no run %q", id))
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func httpError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
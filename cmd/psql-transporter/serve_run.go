@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jayps/psql-transporter/internal/config"
+	"github.com/jayps/psql-transporter/internal/policy"
+	"github.com/jayps/psql-transporter/internal/psql"
+	"github.com/jayps/psql-transporter/internal/ui"
+)
+
+// runRequest is a transfer asked for over the API: a recipe, or what the flags of a
+// custom transfer would say. Confirm stands in for the confirmation prompt: it must
+// name every destination.
+type runRequest struct {
+	Recipe        string   `json:"recipe,omitempty"`
+	Source        string   `json:"source,omitempty"`
+	To            []string `json:"to,omitempty"`
+	Mode          string   `json:"mode,omitempty"`
+	Tables        []string `json:"tables,omitempty"`
+	DeleteMissing bool     `json:"delete_missing,omitempty"`
+	Full          bool     `json:"full,omitempty"`
+	IntoSchema    string   `json:"into_schema,omitempty"`
+	Confirm       []string `json:"confirm,omitempty"`
+}
+
+// resolve returns the config and the unattended options of the transfer r asks for.
+func (r runRequest) resolve(c config.Config) (config.Config, transferOptions, error) {
+	var o transferOptions
+	if r.Recipe != "" {
+		rec, err := c.Recipe(r.Recipe)
+		if err != nil {
+			return c, o, err
+		}
+		if err := rec.Check(c); err != nil {
+			return c, o, err
+		}
+		c, o = rec.Apply(c), recipeOptions(rec)
+	} else {
+		if r.Source == "" || len(r.To) == 0 {
+			return c, o, errors.New("a transfer needs a source and at least one destination")
+		}
+		o = transferOptions{
+			source:        r.Source,
+			to:            r.To,
+			mode:          r.Mode,
+			mergeTables:   r.Tables,
+			deleteMissing: r.DeleteMissing,
+			full:          r.Full,
+			intoSchema:    r.IntoSchema,
+			parallel:      2,
+		}
+	}
+	var err error
+	if o.mode, err = config.ParseMode(o.mode); err != nil {
+		return c, o, err
+	}
+	o.unattended, o.confirmed = true, r.Confirm
+	return c, o, nil
+}
+
+// title describes the transfer r asks for in a few words.
+func (r runRequest) title() string {
+	if r.Recipe != "" {
+		return "recipe " + r.Recipe
+	}
+	mode, _ := config.ParseMode(r.Mode)
+	return fmt.Sprintf("%s → %s (%s)", r.Source, strings.Join(r.To, ", "), mode)
+}
+
+// preview is what a transfer will do, shown before it is confirmed.
+type preview struct {
+	Title        string               `json:"title"`
+	Destinations []previewDestination `json:"destinations"`
+}
+
+// previewDestination is what a transfer will do to one destination.
+type previewDestination struct {
+	Name        string        `json:"name"`
+	Environment string        `json:"environment,omitempty"`
+	Typed       bool          `json:"typed"`             // must be confirmed by typing its name
+	Blocked     string        `json:"blocked,omitempty"` // why the transfer is refused
+	Wiped       bool          `json:"wiped"`             // its tables are dropped or truncated
+	Tables      []tableImpact `json:"tables,omitempty"`
+	Warning     string        `json:"warning,omitempty"` // why Tables is missing or incomplete
+}
+
+// previewRun works out what the transfer r asks for will do, as the interactive flow
+// shows it before the confirmation.
+func previewRun(ctx context.Context, c config.Config, r runRequest) (preview, error) {
+	c, o, err := r.resolve(c)
+	if err != nil {
+		return preview{}, err
+	}
+	var src *config.Source
+	if o.source != "" {
+		if src, err = sourceByName(c, o.source); err != nil {
+			return preview{}, err
+		}
+	}
+	multi := o.allDatabases || len(o.databases) > 0
+	p := preview{Title: r.title()}
+	for _, name := range o.to {
+		dst, err := sourceByName(c, name)
+		if err != nil {
+			return preview{}, err
+		}
+		d := previewDestination{
+			Name:        dst.Name,
+			Environment: dst.Environment,
+			Wiped:       (o.mode == config.ModeFull || o.mode == config.ModeDataOnly) && !multi,
+		}
+		if d.Typed, err = c.NeedsTypedConfirmation(*dst); err != nil {
+			return preview{}, err
+		}
+		policySrc := policy.File
+		if src != nil {
+			policySrc = src.Name
+		}
+		switch {
+		case dst.Protected:
+			d.Blocked = fmt.Sprintf("destination %q is protected", dst.Name)
+		case src != nil && src.Name == dst.Name && o.intoSchema == "":
+			d.Blocked = "source and destination cannot be the same"
+		default:
+			if err := checkPolicies(c, policySrc, dst.Name, o.mode); err != nil {
+				d.Blocked = err.Error()
+			}
+		}
+		if d.Wiped {
+			d.Tables, d.Warning = previewTables(ctx, *dst, src, o)
+		}
+		p.Destinations = append(p.Destinations, d)
+	}
+	return p, nil
+}
+
+// previewTables is showImpact for the API: what the transfer o does to each table of
+// dst, and a warning if that can't be told in full.
+func previewTables(ctx context.Context, dst config.Source, src *config.Source, o transferOptions) ([]tableImpact, string) {
+	schema := psql.WipeSchema
+	if o.intoSchema != "" {
+		schema = o.intoSchema
+	}
+	tables, err := psql.Tables(ctx, toConn(dst), []string{schema})
+	if err != nil {
+		return nil, fmt.Sprintf("could not preview the destination: %v", err)
+	}
+	var names []string
+	if src == nil {
+		names, err = psql.DumpTables(o.file)
+	} else {
+		names, err = sourceTables(ctx, *src)
+		for i, n := range names {
+			names[i] = schema + strings.TrimPrefix(n, psql.WipeSchema)
+		}
+	}
+	if err != nil {
+		return tableImpacts(dst, tables, nil), fmt.Sprintf("could not list incoming tables: %v", err)
+	}
+	return tableImpacts(dst, tables, names), ""
+}
+
+// newServeRunCmd is the worker serve starts for each run, so that every run has its
+// own output and can be stopped with a signal.
+func newServeRunCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:           "serve-run",
+		Short:         "Run a transfer for serve, described by a JSON request on stdin",
+		Hidden:        true,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var r runRequest
+			if err := json.NewDecoder(os.Stdin).Decode(&r); err != nil {
+				return fmt.Errorf("reading the request: %w", err)
+			}
+			c, err := config.Load(filepath.Join(".", config.DefaultFile))
+			if err != nil {
+				return err
+			}
+			c, o, err := r.resolve(c)
+			if err != nil {
+				return err
+			}
+			ui.Plain()
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return runTransfer(ctx, c, o)
+		},
+	}
+}
+
+// workerWaitDelay is how long a cancelled worker has to exit before it is killed:
+// long enough for its failure hooks.
+const workerWaitDelay = 6 * time.Minute
+
+// runInfo describes a run started over the API.
+type runInfo struct {
+	ID       string     `json:"id"`
+	Title    string     `json:"title"`
+	Request  runRequest `json:"request"`
+	Started  time.Time  `json:"started"`
+	Finished time.Time  `json:"finished,omitzero"`
+	Status   string     `json:"status"` // config.RunRunning, RunSucceeded or RunFailed
+	Error    string     `json:"error,omitempty"`
+}
+
+// serveRun is a run started over the API, with its output.
+type serveRun struct {
+	cancel context.CancelFunc
+	done   chan struct{} // closed when the run has ended
+
+	mu      sync.Mutex
+	info    runInfo
+	lines   []string
+	changed chan struct{} // closed and replaced whenever a line is added or the run ends
+}
+
+// startRun starts a worker running r and returns the run. Its output is collected
+// until the worker exits.
+func startRun(ctx context.Context, exe, id string, r runRequest) (*serveRun, error) {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	cmd := exec.CommandContext(ctx, exe, "serve-run")
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	cmd.WaitDelay = workerWaitDelay
+	cmd.Stdin = strings.NewReader(string(body))
+	out, w, err := os.Pipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	cmd.Stdout, cmd.Stderr = w, w
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		out.Close()
+		cancel()
+		return nil, err
+	}
+
+	run := &serveRun{
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		info:    runInfo{ID: id, Title: r.title(), Request: r, Started: time.Now(), Status: config.RunRunning},
+		changed: make(chan struct{}),
+	}
+	go func() {
+		defer cancel()
+		sc := bufio.NewScanner(out)
+		sc.Buffer(make([]byte, 64*1024), 1024*1024)
+		for sc.Scan() {
+			run.add(strings.TrimRight(sc.Text(), "\r"))
+		}
+		out.Close()
+		run.finish(cmd.Wait(), ctx.Err() != nil)
+	}()
+	return run, nil
+}
+
+func (r *serveRun) add(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = append(r.lines, line)
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// finish records how the worker ended. The worker prints the error that failed the
+// run last.
+func (r *serveRun) finish(err error, cancelled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.info.Finished = time.Now()
+	r.info.Status = config.RunSucceeded
+	if err != nil {
+		r.info.Status, r.info.Error = config.RunFailed, err.Error()
+		for i := len(r.lines) - 1; i >= 0; i-- {
+			if line := strings.TrimSpace(r.lines[i]); line != "" {
+				r.info.Error = line
+				break
+			}
+		}
+		if cancelled {
+			r.info.Error = "cancelled: " + r.info.Error
+		}
+	}
+	close(r.changed)
+	close(r.done)
+}
+
+// Info returns a snapshot of what is known about the run.
+func (r *serveRun) Info() runInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.info
+}
+
+// since returns the output lines from the n-th on, the run's state, and a channel
+// that is closed when either changes.
+func (r *serveRun) since(n int) ([]string, runInfo, <-chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lines[n:], r.info, r.changed
+}
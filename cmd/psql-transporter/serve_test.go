@@ -0,0 +1,116 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jayps/psql-transporter/internal/config"
+)
+
+func testConfig() config.Config {
+	return config.Config{
+		Sources: []config.Source{
+			{Name: "staging", Environment: "staging"},
+			{Name: "dev", Environment: "dev"},
+			{Name: "qa", Environment: "staging"},
+		},
+		Recipes: []config.Recipe{
+			{Name: "refresh-dev", Source: "staging", To: []string{"dev"}, Mode: config.ModeDataOnly, Maintenance: []string{"analyze"}},
+			{Name: "broken", Source: "prod", To: []string{"dev"}},
+		},
+	}
+}
+
+func TestRunRequestResolve(t *testing.T) {
+	c := testConfig()
+	tests := []struct {
+		name    string
+		r       runRequest
+		want    transferOptions
+		wantErr string
+	}{
+		{
+			name: "recipe",
+			r:    runRequest{Recipe: "refresh-dev", Confirm: []string{"dev"}},
+			want: transferOptions{source: "staging", to: []string{"dev"}, mode: config.ModeDataOnly, parallel: 2, unattended: true, confirmed: []string{"dev"}},
+		},
+		{
+			name: "custom transfer",
+			r:    runRequest{Source: "staging", To: []string{"qa"}, Confirm: []string{"qa"}},
+			want: transferOptions{source: "staging", to: []string{"qa"}, mode: config.ModeFull, parallel: 2, unattended: true, confirmed: []string{"qa"}},
+		},
+		{
+			name: "custom merge",
+			r:    runRequest{Source: "staging", To: []string{"dev"}, Mode: config.ModeMerge, Tables: []string{"countries"}, DeleteMissing: true},
+			want: transferOptions{source: "staging", to: []string{"dev"}, mode: config.ModeMerge, mergeTables: []string{"countries"}, deleteMissing: true, parallel: 2, unattended: true},
+		},
+		{name: "unknown recipe", r: runRequest{Recipe: "nope"}, wantErr: `no recipe named "nope"`},
+		{name: "broken recipe", r: runRequest{Recipe: "broken"}, wantErr: `no source named "prod"`},
+		{name: "no destination", r: runRequest{Source: "staging"}, wantErr: "needs a source and at least one destination"},
+		{name: "unknown mode", r: runRequest{Source: "staging", To: []string{"dev"}, Mode: "fast"}, wantErr: `unknown mode "fast"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc, o, err := tt.r.resolve(c)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("resolve() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(o, tt.want) {
+				t.Errorf("resolve() options = %+v, want %+v", o, tt.want)
+			}
+			if tt.r.Recipe != "" && !reflect.DeepEqual(rc.Sources[1].Maintenance, []string{"analyze"}) {
+				t.Errorf("recipe not applied: dev maintenance = %q", rc.Sources[1].Maintenance)
+			}
+		})
+	}
+}
+
+func TestUnattendedConfirm(t *testing.T) {
+	c := testConfig()
+	staging, dev, qa := &c.Sources[0], &c.Sources[1], &c.Sources[2]
+	tests := []struct {
+		name      string
+		confirmed []string
+		dsts      []*config.Source
+		wantErr   string
+	}{
+		{name: "yes/no destination", dsts: []*config.Source{dev}},
+		{name: "typed destination", dsts: []*config.Source{qa}, wantErr: `destination "qa" must be confirmed by typing its name`},
+		{name: "typed destination confirmed in advance", confirmed: []string{"qa"}, dsts: []*config.Source{qa}},
+		{name: "another destination confirmed", confirmed: []string{"staging"}, dsts: []*config.Source{dev, qa}, wantErr: `destination "qa"`},
+		{name: "all confirmed", confirmed: []string{"qa", "staging"}, dsts: []*config.Source{qa, staging, dev}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := transferOptions{unattended: true, confirmed: tt.confirmed}
+			ok, err := o.confirm(c, tt.dsts, "Continue?")
+			if tt.wantErr != "" {
+				if ok || err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("confirm() = %v, %v; want %q", ok, err, tt.wantErr)
+				}
+				return
+			}
+			if !ok || err != nil {
+				t.Errorf("confirm() = %v, %v; want it confirmed", ok, err)
+			}
+		})
+	}
+}
+
+func TestCheckServable(t *testing.T) {
+	c := testConfig()
+	if err := checkServable(c); err != nil {
+		t.Errorf("checkServable() = %v", err)
+	}
+	c.Policies.Users = []config.UserRule{{Users: []string{"alice"}, Sources: []string{"*"}, Destinations: []string{"*"}}}
+	if err := checkServable(c); err == nil || !strings.Contains(err.Error(), "policies.users") {
+		t.Errorf("checkServable() = %v, want policies.users refused", err)
+	}
+}
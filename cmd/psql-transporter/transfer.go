@@ -0,0 +1,818 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+
+	"github.com/jayps/psql-transporter/internal/config"
+	"github.com/jayps/psql-transporter/internal/hooks"
+	"github.com/jayps/psql-transporter/internal/psql"
+	"github.com/jayps/psql-transporter/internal/ui"
+)
+
+// transfer refreshes a destination database from a source database or a dump file,
+// or exports a source database to a dump file. It assumes policies were checked, the
+// wipe was confirmed and the destination is locked.
+type transfer struct {
+	cfg     config.Config
+	src     *config.Source // nil when importing a dump file
+	srcFile string         // dump file to import when src is nil
+	dst     *config.Source // nil when exporting to a dump file
+	dstFile string         // dump file to write when dst is nil
+	mode    string         // one of config.Modes
+
+	merge       config.Merge       // merge mode: tables to upsert, resolved from flags and dst
+	incremental config.Incremental // incremental mode: tables and where watermarks are kept
+	full        bool               // incremental mode: ignore the stored watermarks and resync every row
+	intoSchema  string             // copy the source's public schema into this schema instead of replacing public
+	globals     *psql.GlobalsPlan  // roles and tablespaces to copy before the import, if enabled
+
+	dumpPath string
+	targets  []string     // data-only: tables to truncate and reload, referencing tables first
+	log      io.Writer    // hook output
+	summary  []summaryRow // timed steps, printed when the run ends
+}
+
+type summaryRow struct {
+	step string
+	took time.Duration
+	note string
+	err  error
+}
+
+func (t *transfer) run(ctx context.Context) (err error) {
+	logFile, err := os.OpenFile(t.cfg.LogPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+	t.log = logFile
+	defer func() {
+		t.printSummary()
+		if err == nil {
+			fmt.Println("All done ✅")
+			return
+		}
+		// Run failure hooks even when the run was interrupted, but don't let them hang.
+		hctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Minute)
+		defer cancel()
+		if herr := t.hook(hctx, hooks.OnFailure, err); herr != nil {
+			pterm.Warning.Println(herr)
+		}
+	}()
+	if t.dst != nil {
+		if err := checkMaintenance(*t.dst); err != nil {
+			return err
+		}
+	}
+
+	if t.mode == config.ModeMerge {
+		if t.src == nil || t.dst == nil {
+			return fmt.Errorf("%s mode needs a source and a destination database", config.ModeMerge)
+		}
+		if err := t.mergeTables(ctx); err != nil {
+			return err
+		}
+		return t.afterImport(ctx, *t.dst)
+	}
+	if t.mode == config.ModeIncremental {
+		if t.src == nil || t.dst == nil {
+			return fmt.Errorf("%s mode needs a source and a destination database", config.ModeIncremental)
+		}
+		if err := t.syncIncremental(ctx); err != nil {
+			return err
+		}
+		return t.afterImport(ctx, *t.dst)
+	}
+
+	dumpOpts := psql.DumpOptions{}
+	importOpts := psql.ImportOptions{}
+	if t.mode == config.ModeDataOnly {
+		if t.src == nil || t.dst == nil {
+			return fmt.Errorf("%s mode needs a source and a destination database", config.ModeDataOnly)
+		}
+		if err := t.timed("Schema check", func() (string, error) { return t.checkDataOnly(ctx) }); err != nil {
+			return err
+		}
+		dumpOpts = psql.DumpOptions{DataOnly: true, Schemas: []string{psql.WipeSchema}}
+		importOpts = psql.ImportOptions{ReplicaRole: true, StopOnError: true}
+	}
+
+	if t.intoSchema != "" {
+		dumpOpts.Schemas = []string{psql.WipeSchema}
+	}
+	// Roles go first so that owners, grants and the import can refer to them.
+	if err := t.applyGlobals(ctx); err != nil {
+		return err
+	}
+	if t.dst != nil && t.mode == config.ModeFull {
+		if err := t.checkRoles(ctx); err != nil {
+			return err
+		}
+		dumpOpts.KeepOwners = t.dst.KeepOwners
+	}
+
+	t.dumpPath = t.srcFile
+	if t.src != nil {
+		t.dumpPath = t.dstFile
+		if t.dst != nil {
+			t.dumpPath = filepath.Join(".", "dump.sql")
+		}
+		if err := t.hook(ctx, hooks.BeforeExport, nil); err != nil {
+			return err
+		}
+		if err := t.timed("Export", func() (string, error) { return "", export(ctx, *t.src, t.dumpPath, dumpOpts) }); err != nil {
+			return err
+		}
+		if t.intoSchema != "" {
+			if err := psql.RewriteSchema(t.dumpPath, psql.WipeSchema, t.intoSchema); err != nil {
+				return err
+			}
+		}
+		if dumpOpts.KeepOwners {
+			if err := psql.RemapRoles(t.dumpPath, t.dst.RoleMap); err != nil {
+				return err
+			}
+		}
+		if err := t.hook(ctx, hooks.AfterExport, nil); err != nil {
+			return err
+		}
+	}
+	if t.dst == nil {
+		fmt.Println("Dump written to", t.dumpPath)
+		if t.intoSchema != "" {
+			fmt.Printf("It restores into schema %s.\n", t.intoSchema)
+		}
+		return nil
+	}
+	dst := *t.dst
+
+	if t.intoSchema != "" {
+		// Only the target schema is touched; the destination's keep_tables, migrations and
+		// post-import settings are about its public schema, so they don't apply.
+		if err := t.hook(ctx, hooks.BeforeWipe, nil); err != nil {
+			return err
+		}
+		if err := t.timed("Wipe", func() (string, error) {
+			return "", ui.RunSteps([]ui.Step{{
+				Title: fmt.Sprintf("Wiping schema %s...", t.intoSchema),
+				Run:   func() error { return psql.WipeSchemaNamed(ctx, toConn(dst), t.intoSchema) },
+			}})
+		}); err != nil {
+			return err
+		}
+		if err := t.timed("Import", func() (string, error) { return "", importDump(ctx, dst, t.dumpPath, importOpts) }); err != nil {
+			return err
+		}
+		if err := t.applyPermissions(ctx, dst, t.intoSchema); err != nil {
+			return err
+		}
+		return t.hook(ctx, hooks.AfterImport, nil)
+	}
+
+	kept, err := saveKeptTables(ctx, dst)
+	if err != nil {
+		return err
+	}
+	if err := t.hook(ctx, hooks.BeforeWipe, nil); err != nil {
+		return keptDataHint(kept, err)
+	}
+	if t.mode == config.ModeDataOnly {
+		err = t.timed("Truncate", func() (string, error) {
+			_, err := ui.StepSpinner(fmt.Sprintf("Truncating %d tables...", len(t.targets)), func() (any, error) {
+				return nil, psql.Truncate(ctx, toConn(dst), t.targets)
+			})
+			return fmt.Sprintf("%d tables", len(t.targets)), err
+		})
+	} else {
+		err = t.timed("Wipe", func() (string, error) {
+			return "", ui.RunSteps([]ui.Step{
+				{Title: "Wiping destination...", Run: func() error { return psql.Wipe(ctx, toConn(dst)) }},
+			})
+		})
+	}
+	if err != nil {
+		return keptDataHint(kept, err)
+	}
+	if err := t.timed("Import", func() (string, error) { return "", importDump(ctx, dst, t.dumpPath, importOpts) }); err != nil {
+		return keptDataHint(kept, err)
+	}
+	return t.finishImport(ctx, dst, kept)
+}
+
+// finishImport runs what follows an import into the public schema of dst: migrations,
+// restoring the kept tables, permissions and the after-import steps.
+func (t *transfer) finishImport(ctx context.Context, dst config.Source, kept keptTables) error {
+	// Migrate before restoring kept rows, which come from the destination's (newer) schema.
+	if dst.Migrate != nil && dst.Migrate.Command != "" {
+		if err := t.timed("Migrations", func() (string, error) { return t.migrate(ctx, dst) }); err != nil {
+			return keptDataHint(kept, err)
+		}
+	}
+	if err := restoreKeptTables(ctx, dst, kept); err != nil {
+		return keptDataHint(kept, err)
+	}
+	kept.cleanup()
+	if t.mode == config.ModeFull {
+		if err := t.applyPermissions(ctx, dst, psql.WipeSchema); err != nil {
+			return err
+		}
+	}
+	return t.afterImport(ctx, dst)
+}
+
+// checkMaintenance fails if dst names a maintenance task that doesn't exist.
+func checkMaintenance(dst config.Source) error {
+	for _, task := range dst.Maintenance {
+		if !slices.Contains(psql.MaintenanceTasks, task) {
+			return fmt.Errorf("destination %q: unknown maintenance task %q (want one of %s)",
+				dst.Name, task, strings.Join(psql.MaintenanceTasks, ", "))
+		}
+	}
+	return nil
+}
+
+// checkRoles fails before anything is changed if the destination's grants are invalid
+// or a role its owner, grants or role_map name doesn't exist there.
+func (t *transfer) checkRoles(ctx context.Context) error {
+	for role, g := range t.dst.Grants {
+		if err := psql.CheckGrant(g.Schema, g.Tables, g.Sequences); err != nil {
+			return fmt.Errorf("destination %q: grants for %s: %w", t.dst.Name, role, err)
+		}
+	}
+	roles := t.dst.Roles()
+	if len(roles) == 0 {
+		return nil
+	}
+	missing, err := psql.MissingRoles(ctx, toConn(*t.dst), roles)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("destination %q has no role(s) %s; create them or fix owner, grants and role_map",
+			t.dst.Name, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// applyPermissions hands the restored schema to the destination's owner and applies
+// its grants.
+func (t *transfer) applyPermissions(ctx context.Context, dst config.Source, schema string) error {
+	if dst.Owner == "" && len(dst.Grants) == 0 {
+		return nil
+	}
+	return t.timed("Permissions", func() (string, error) {
+		var steps []ui.Step
+		if dst.Owner != "" {
+			steps = append(steps, ui.Step{
+				Title: fmt.Sprintf("Handing %s to %s...", schema, dst.Owner),
+				Run:   func() error { return psql.SetOwner(ctx, toConn(dst), schema, dst.Owner) },
+			})
+		}
+		roles := make([]string, 0, len(dst.Grants))
+		for role := range dst.Grants {
+			roles = append(roles, role)
+		}
+		slices.Sort(roles)
+		for _, role := range roles {
+			g := dst.Grants[role]
+			steps = append(steps, ui.Step{
+				Title: fmt.Sprintf("Granting %s...", role),
+				Run: func() error {
+					return psql.Grant(ctx, toConn(dst), schema, dst.Owner, role, g.Schema, g.Tables, g.Sequences)
+				},
+			})
+		}
+		return fmt.Sprintf("%d role(s) granted", len(roles)), ui.RunSteps(steps)
+	})
+}
+
+// afterImport runs the destination's post_import SQL, maintenance tasks, assertions
+// and after_import hooks once its data is in place.
+func (t *transfer) afterImport(ctx context.Context, dst config.Source) error {
+	if len(dst.PostImport) > 0 {
+		if err := t.timed("Post-import SQL", func() (string, error) { return "", runPostImport(ctx, dst) }); err != nil {
+			return err
+		}
+	}
+	if err := t.maintain(ctx, dst); err != nil {
+		return err
+	}
+	if len(dst.Assert) > 0 {
+		if err := t.timed("Assertions", func() (string, error) { return "", runAssertions(ctx, dst) }); err != nil {
+			return err
+		}
+	}
+	return t.hook(ctx, hooks.AfterImport, nil)
+}
+
+// timed runs fn and records its duration and note in the run summary.
+func (t *transfer) timed(step string, fn func() (string, error)) error {
+	start := time.Now()
+	note, err := fn()
+	t.summary = append(t.summary, summaryRow{step: step, took: time.Since(start), note: note, err: err})
+	return err
+}
+
+func (t *transfer) printSummary() {
+	if len(t.summary) == 0 {
+		return
+	}
+	rows := make([][]string, 0, len(t.summary))
+	for _, r := range t.summary {
+		result := pterm.FgGreen.Sprint("ok")
+		if r.err != nil {
+			result = pterm.FgRed.Sprint("failed")
+		}
+		if r.note != "" {
+			result += ", " + r.note
+		}
+		rows = append(rows, []string{r.step, r.took.Round(100 * time.Millisecond).String(), result})
+	}
+	fmt.Println()
+	fmt.Println("Run summary:")
+	ui.Table([]string{"Step", "Took", "Result"}, rows)
+}
+
+// maintain runs the destination's selected maintenance tasks in their recommended
+// order, timing each one.
+func (t *transfer) maintain(ctx context.Context, dst config.Source) error {
+	for _, task := range psql.MaintenanceTasks {
+		if !slices.Contains(dst.Maintenance, task) {
+			continue
+		}
+		err := t.timed("Maintenance: "+task, func() (string, error) {
+			return ui.StepSpinner(fmt.Sprintf("Running %s...", task), func() (string, error) {
+				return psql.Maintain(ctx, toConn(dst), task)
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hook runs the global, source and destination hooks for phase. runErr is the error
+// that failed the run, for on_failure hooks.
+func (t *transfer) hook(ctx context.Context, phase string, runErr error) error {
+	sets := []config.Hooks{t.cfg.Hooks}
+	env := hooks.Env{Phase: phase, Source: t.srcFile, Destination: t.dstFile, Dump: t.dumpPath, Status: "running"}
+	if t.src != nil {
+		sets = append(sets, t.src.Hooks)
+		env.Source = t.src.Name
+	}
+	if t.dst != nil {
+		sets = append(sets, t.dst.Hooks)
+		env.Destination = t.dst.Name
+	}
+	switch {
+	case runErr != nil:
+		env.Status, env.Error = "failed", runErr.Error()
+	case phase == hooks.AfterImport:
+		env.Status = "succeeded"
+	}
+
+	list := hooks.Select(phase, sets...)
+	if len(list) == 0 {
+		return nil
+	}
+	var warnings []error
+	_, err := ui.StepSpinner(fmt.Sprintf("Running %s hooks...", phase), func() (any, error) {
+		w, err := hooks.Run(ctx, list, env, t.log)
+		warnings = w
+		return nil, err
+	})
+	for _, w := range warnings {
+		pterm.Warning.Printfln("%v (continuing)", w)
+	}
+	return err
+}
+
+// migrate runs the destination's migration command as a step and reports which
+// migrations it applied. The command's output goes to the log.
+func (t *transfer) migrate(ctx context.Context, dst config.Source) (string, error) {
+	table, column := dst.Migrate.VersionTable()
+	before, err := psql.AppliedMigrations(ctx, toConn(dst), table, column)
+	if err != nil {
+		return "", err
+	}
+
+	if err := ui.RunSteps([]ui.Step{{Title: "Running migrations...", Run: func() error {
+		fmt.Fprintf(t.log, "[%s] migrate: %s\n", time.Now().Format(time.RFC3339), dst.Migrate.Command)
+		cmd := psql.Command(ctx, "sh", "-c", dst.Migrate.Command)
+		cmd.Env = toConn(dst).Environ()
+		var out bytes.Buffer
+		cmd.Stdout = &out
+		cmd.Stderr = &out
+		err := cmd.Run()
+		t.log.Write(out.Bytes())
+		if err != nil {
+			return fmt.Errorf("migration command %q failed: %v\n%s", dst.Migrate.Command, err, out.String())
+		}
+		return nil
+	}}}); err != nil {
+		return "", err
+	}
+
+	after, err := psql.AppliedMigrations(ctx, toConn(dst), table, column)
+	if err != nil {
+		return "", err
+	}
+	var applied []string
+	for _, v := range after {
+		if !slices.Contains(before, v) {
+			applied = append(applied, v)
+		}
+	}
+	switch {
+	case after == nil:
+		fmt.Printf("Migrations ran; no %s table to report applied versions from.\n", table)
+		return "", nil
+	case len(applied) == 0:
+		fmt.Println("No pending migrations.")
+	default:
+		fmt.Printf("Applied %d migration(s): %s\n", len(applied), strings.Join(applied, ", "))
+	}
+	return fmt.Sprintf("%d applied", len(applied)), nil
+}
+
+// mergeTables saves the merge tables of the source as CSV and upserts them into the
+// destination, then prints what changed in each table.
+func (t *transfer) mergeTables(ctx context.Context) error {
+	dir, err := os.MkdirTemp("", "psql-transporter-merge-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := t.hook(ctx, hooks.BeforeExport, nil); err != nil {
+		return err
+	}
+	files := make(map[string]string, len(t.merge.Tables))
+	err = t.timed("Export", func() (string, error) {
+		steps := make([]ui.Step, 0, len(t.merge.Tables))
+		for _, table := range t.merge.Tables {
+			files[table] = filepath.Join(dir, table+".csv")
+			steps = append(steps, ui.Step{
+				Title: fmt.Sprintf("Exporting %s...", table),
+				Run:   func() error { return psql.SaveTable(ctx, toConn(*t.src), table, files[table]) },
+			})
+		}
+		return fmt.Sprintf("%d tables", len(steps)), ui.RunSteps(steps)
+	})
+	if err != nil {
+		return err
+	}
+	if err := t.hook(ctx, hooks.AfterExport, nil); err != nil {
+		return err
+	}
+	if err := t.hook(ctx, hooks.BeforeWipe, nil); err != nil {
+		return err
+	}
+
+	return t.mergeFiles(ctx, files, t.merge.DeleteMissing)
+}
+
+// mergeFiles upserts the saved rows in files into the destination as a timed step
+// and prints what changed in each table.
+func (t *transfer) mergeFiles(ctx context.Context, files map[string]string, deleteMissing bool) error {
+	var results []psql.MergeResult
+	err := t.timed("Merge", func() (string, error) {
+		res, err := ui.StepSpinner("Merging...", func() ([]psql.MergeResult, error) {
+			return psql.Merge(ctx, toConn(*t.dst), files, deleteMissing)
+		})
+		results = res
+		var total psql.MergeResult
+		for _, r := range res {
+			total.Inserted += r.Inserted
+			total.Updated += r.Updated
+			total.Deleted += r.Deleted
+		}
+		return fmt.Sprintf("%d inserted, %d updated, %d deleted", total.Inserted, total.Updated, total.Deleted), err
+	})
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(results))
+	for _, r := range results {
+		deleted := "-"
+		if deleteMissing {
+			deleted = fmt.Sprint(r.Deleted)
+		}
+		rows = append(rows, []string{r.Table, fmt.Sprint(r.Inserted), fmt.Sprint(r.Updated), fmt.Sprint(r.Unchanged), deleted})
+	}
+	ui.Table([]string{"Table", "Inserted", "Updated", "Unchanged", "Deleted"}, rows)
+	return nil
+}
+
+// syncIncremental copies the rows of the incremental tables whose watermark moved
+// since the last successful sync and upserts them into the destination. The new
+// watermarks are stored only after the upsert committed. With full, every row is
+// copied and destination rows missing from the source are deleted.
+func (t *transfer) syncIncremental(ctx context.Context) error {
+	src, dst := *t.src, *t.dst
+	key := config.SyncKey(src.Name, dst.Name)
+	var (
+		last map[string]string
+		err  error
+	)
+	if t.incremental.State == config.StateInTable {
+		last, err = psql.LoadWatermarks(ctx, toConn(dst), src.Name)
+	} else {
+		var state config.State
+		state, err = config.LoadState(t.cfg.StatePath())
+		last = state.Watermarks[key]
+	}
+	if err != nil {
+		return err
+	}
+	if t.full {
+		last = nil
+	}
+
+	dir, err := os.MkdirTemp("", "psql-transporter-sync-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := t.hook(ctx, hooks.BeforeExport, nil); err != nil {
+		return err
+	}
+	files := make(map[string]string)
+	next := make(map[string]string)
+	err = t.timed("Export", func() (string, error) {
+		for _, w := range t.incremental.Tables {
+			_, err := ui.StepSpinner(fmt.Sprintf("Exporting %s...", w.Table), func() (any, error) {
+				high, err := psql.MaxValue(ctx, toConn(src), w.Table, w.Column)
+				if err != nil {
+					return nil, err
+				}
+				var where string
+				switch {
+				case t.full:
+				case high == "":
+					return nil, nil // no rows with a watermark yet
+				default:
+					where = psql.ChangedSince(w.Column, last[w.Table], high)
+				}
+				if high != "" {
+					next[w.Table] = high
+				}
+				files[w.Table] = filepath.Join(dir, w.Table+".csv")
+				return nil, psql.SaveRows(ctx, toConn(src), w.Table, where, files[w.Table])
+			})
+			if err != nil {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("%d of %d tables", len(files), len(t.incremental.Tables)), nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := t.hook(ctx, hooks.AfterExport, nil); err != nil {
+		return err
+	}
+	if err := t.hook(ctx, hooks.BeforeWipe, nil); err != nil {
+		return err
+	}
+	if len(files) > 0 {
+		if err := t.mergeFiles(ctx, files, t.full); err != nil {
+			return err
+		}
+	}
+
+	if t.incremental.State == config.StateInTable {
+		return psql.StoreWatermarks(ctx, toConn(dst), src.Name, next)
+	}
+	return config.UpdateState(t.cfg.StatePath(), func(st *config.State) {
+		if st.Watermarks == nil {
+			st.Watermarks = make(map[string]map[string]string)
+		}
+		if st.Watermarks[key] == nil {
+			st.Watermarks[key] = make(map[string]string)
+		}
+		for table, mark := range next {
+			st.Watermarks[key][table] = mark
+		}
+	})
+}
+
+// checkDataOnly compares the source and destination tables before a data-only load
+// and fails, listing every problem, if the data would not fit. On success it records
+// the tables to truncate in an order that satisfies foreign keys.
+func (t *transfer) checkDataOnly(ctx context.Context) (string, error) {
+	return ui.StepSpinner("Comparing source and destination columns...", func() (string, error) {
+		schemas := []string{psql.WipeSchema}
+		srcCols, err := psql.Columns(ctx, toConn(*t.src), schemas)
+		if err != nil {
+			return "", err
+		}
+		dstCols, err := psql.Columns(ctx, toConn(*t.dst), schemas)
+		if err != nil {
+			return "", err
+		}
+		fks, err := psql.ForeignKeys(ctx, toConn(*t.dst), schemas)
+		if err != nil {
+			return "", err
+		}
+
+		tables := make([]string, 0, len(srcCols))
+		for table := range srcCols {
+			tables = append(tables, table)
+		}
+		slices.Sort(tables)
+		problems := psql.CompareColumns(srcCols, dstCols)
+		for _, fk := range psql.TruncateBlockers(tables, fks) {
+			problems = append(problems, fmt.Sprintf("%s: referenced by %s (%s), which is not in the source, so it can't be truncated", fk.Parent, fk.Child, fk.Name))
+		}
+		if len(problems) > 0 {
+			return "", fmt.Errorf("source and destination schemas don't match; nothing was changed:\n  %s", strings.Join(problems, "\n  "))
+		}
+		t.targets = psql.TruncateOrder(tables, fks)
+		return fmt.Sprintf("%d tables match", len(tables)), nil
+	})
+}
+
+// export dumps src to path, showing the dump size in the spinner as it grows.
+func export(ctx context.Context, src config.Source, path string, opts psql.DumpOptions) error {
+	spinner := ui.StartSpinner("Exporting...")
+	err := psql.DumpWithOptions(ctx, toConn(src), path, opts, func(sz int64) {
+		spinner.UpdateText(fmt.Sprintf("Exporting... (%s)", humanSize(sz)))
+	})
+	if err != nil {
+		spinner.Fail(fmt.Sprintf("Export failed: %v", err))
+		return err
+	}
+	spinner.Success("Export completed")
+	return nil
+}
+
+// importDump loads the dump at path into dst, showing progress in the spinner.
+func importDump(ctx context.Context, dst config.Source, path string, opts psql.ImportOptions) error {
+	spinner := ui.StartSpinner("Importing...")
+	err := psql.ImportWithOptions(ctx, toConn(dst), path, opts, func(done, total int64) {
+		var text string
+		if total > 0 {
+			pct := float64(done) / float64(total) * 100
+			text = fmt.Sprintf("Importing... (%.1f%%)", pct)
+		} else {
+			text = fmt.Sprintf("Importing... (%s)", humanSize(done))
+		}
+		spinner.UpdateText(text)
+	})
+	if err != nil {
+		spinner.Fail(fmt.Sprintf("Import failed: %v", err))
+		return err
+	}
+	spinner.Success("Import completed")
+	return nil
+}
+
+// runPostImport runs the destination's post_import SQL in order, stopping at the first failure.
+func runPostImport(ctx context.Context, dst config.Source) error {
+	steps := make([]ui.Step, 0, len(dst.PostImport))
+	for _, s := range dst.PostImport {
+		if s.File != "" {
+			steps = append(steps, ui.Step{
+				Title: fmt.Sprintf("Running %s...", s.File),
+				Run:   func() error { return psql.ExecFile(ctx, toConn(dst), s.File) },
+			})
+			continue
+		}
+		steps = append(steps, ui.Step{
+			Title: fmt.Sprintf("Running %s...", oneLine(s.SQL)),
+			Run: func() error {
+				_, err := psql.Query(ctx, toConn(dst), s.SQL)
+				return err
+			},
+		})
+	}
+	return ui.RunSteps(steps)
+}
+
+// runAssertions checks every assert query of dst and fails the run if any does not
+// hold, listing each failing query with its result.
+func runAssertions(ctx context.Context, dst config.Source) error {
+	failed := 0
+	for _, q := range dst.Assert {
+		title := fmt.Sprintf("Checking %s...", oneLine(q))
+		_, err := ui.StepSpinner(title, func() (any, error) {
+			ok, result, err := psql.Assert(ctx, toConn(dst), q)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, fmt.Errorf("assertion failed: %s returned %s", strings.TrimSpace(q), result)
+			}
+			return nil, nil
+		})
+		if err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d assertion(s) on %q failed", failed, len(dst.Assert), dst.Name)
+	}
+	return nil
+}
+
+// oneLine shortens SQL to a single line for step titles.
+func oneLine(sql string) string {
+	s := strings.Join(strings.Fields(sql), " ")
+	if len(s) > 60 {
+		s = s[:57] + "..."
+	}
+	return s
+}
+
+// keptTables holds the destination rows saved before a wipe.
+type keptTables struct {
+	dir    string
+	tables []config.KeepTable
+}
+
+func (k keptTables) file(t config.KeepTable) string { return filepath.Join(k.dir, t.Table+".csv") }
+
+func (k keptTables) cleanup() {
+	if k.dir != "" {
+		os.RemoveAll(k.dir)
+	}
+}
+
+// keptDataHint points at the saved rows when a run fails after they were taken, so
+// they can be restored by hand.
+func keptDataHint(k keptTables, err error) error {
+	if len(k.tables) == 0 {
+		return err
+	}
+	return fmt.Errorf("%w\nrows of the kept tables were saved as CSV in %s", err, k.dir)
+}
+
+// saveKeptTables exports the rows of dst's keep_tables that exist into a temporary directory.
+func saveKeptTables(ctx context.Context, dst config.Source) (keptTables, error) {
+	var k keptTables
+	if len(dst.KeepTables) == 0 {
+		return k, nil
+	}
+	dir, err := os.MkdirTemp("", "psql-transporter-keep-*")
+	if err != nil {
+		return k, err
+	}
+	k.dir = dir
+	for _, t := range dst.KeepTables {
+		title := fmt.Sprintf("Saving %s...", t.Table)
+		_, err := ui.StepSpinner(title, func() (any, error) {
+			exists, err := psql.TableExists(ctx, toConn(dst), t.Table)
+			if err != nil || !exists {
+				return nil, err
+			}
+			k.tables = append(k.tables, t)
+			return nil, psql.SaveTable(ctx, toConn(dst), t.Table, k.file(t))
+		})
+		if err != nil {
+			k.cleanup()
+			return keptTables{}, err
+		}
+	}
+	return k, nil
+}
+
+// restoreKeptTables re-applies the saved rows and reports rows that clashed with incoming data.
+func restoreKeptTables(ctx context.Context, dst config.Source, k keptTables) error {
+	for _, t := range k.tables {
+		title := fmt.Sprintf("Restoring %s...", t.Table)
+		res, err := ui.StepSpinner(title, func() (psql.RestoreResult, error) {
+			exists, err := psql.TableExists(ctx, toConn(dst), t.Table)
+			if err != nil {
+				return psql.RestoreResult{}, err
+			}
+			if !exists {
+				return psql.RestoreResult{}, fmt.Errorf("%s is not in the imported data", t.Table)
+			}
+			return psql.RestoreTable(ctx, toConn(dst), t.Table, k.file(t), t.Mode)
+		})
+		if err != nil {
+			return err
+		}
+		switch {
+		case res.Updated > 0:
+			pterm.Warning.Printfln("%s: %d kept row(s) overwrote incoming rows with the same primary key", t.Table, res.Updated)
+		case res.Discarded > 0:
+			pterm.Warning.Printfln("%s: %d incoming row(s) were replaced by %d kept row(s)", t.Table, res.Discarded, res.Inserted)
+		}
+	}
+	return nil
+}
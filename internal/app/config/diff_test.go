@@ -0,0 +1,43 @@
+package config
+
+import "testing"
+
+func TestLineDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "unchanged",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: "  a\n  b\n",
+		},
+		{
+			name: "line added at the top",
+			a:    "sources: []\n",
+			b:    "version: 1\nsources: []\n",
+			want: "+ version: 1\n  sources: []\n",
+		},
+		{
+			name: "line changed",
+			a:    "a\nb\nc\n",
+			b:    "a\nx\nc\n",
+			want: "  a\n+ x\n- b\n  c\n",
+		},
+		{
+			name: "line removed",
+			a:    "a\nb\nc",
+			b:    "a\nc",
+			want: "  a\n- b\n  c\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lineDiff(tt.a, tt.b); got != tt.want {
+				t.Errorf("lineDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
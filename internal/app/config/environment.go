@@ -0,0 +1,154 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Risk orders environments from harmless to critical.
+type Risk int
+
+const (
+	RiskNone Risk = iota // no environment label
+	RiskLocal
+	RiskTest
+	RiskStaging
+	RiskProduction
+)
+
+var riskNames = map[string]Risk{
+	"local":      RiskLocal,
+	"dev":        RiskLocal,
+	"test":       RiskTest,
+	"qa":         RiskTest,
+	"staging":    RiskStaging,
+	"prod":       RiskProduction,
+	"production": RiskProduction,
+}
+
+var riskColors = map[Risk]string{
+	RiskLocal:      "green",
+	RiskTest:       "cyan",
+	RiskStaging:    "yellow",
+	RiskProduction: "red",
+}
+
+// DefaultConfirmByTyping is the risk level from which destinations must be confirmed
+// by typing their name when confirm_by_typing is not set.
+const DefaultConfirmByTyping = "staging"
+
+// Environment describes a custom environment label, e.g. {name: perf, risk: staging, color: magenta}.
+// The built-in labels dev, local, test, qa, staging, prod and production need no definition.
+type Environment struct {
+	Name  string `yaml:"name"`
+	Risk  string `yaml:"risk"`            // one of the built-in labels
+	Color string `yaml:"color,omitempty"` // defaults to the color of its risk level
+}
+
+// ParseRisk maps a built-in environment label to its risk level.
+func ParseRisk(label string) (Risk, error) {
+	if label == "" {
+		return RiskNone, nil
+	}
+	if r, ok := riskNames[strings.ToLower(label)]; ok {
+		return r, nil
+	}
+	return RiskNone, fmt.Errorf("unknown risk level %q (want one of local, test, staging, production)", label)
+}
+
+// environment returns the custom definition for label, if any.
+func (c Config) environment(label string) (Environment, bool) {
+	for _, e := range c.Environments {
+		if strings.EqualFold(e.Name, label) {
+			return e, true
+		}
+	}
+	return Environment{}, false
+}
+
+// checkEnvironments makes sure every custom environment has a built-in risk level
+// and every source's environment label is built in or defined, so that a typo can't
+// quietly make a destination count as unlabelled.
+func (c Config) checkEnvironments() error {
+	builtin := slices.Sorted(maps.Keys(riskNames))
+	for _, e := range c.Environments {
+		if e.Name == "" {
+			return errors.New("environments: every environment needs a name")
+		}
+		if _, ok := riskNames[strings.ToLower(e.Risk)]; !ok {
+			return fmt.Errorf("environment %q: unknown risk %q (want one of %s)", e.Name, e.Risk, strings.Join(builtin, ", "))
+		}
+	}
+	for _, s := range c.Sources {
+		if s.Environment == "" {
+			continue
+		}
+		if _, ok := riskNames[strings.ToLower(s.Environment)]; ok {
+			continue
+		}
+		if _, ok := c.environment(s.Environment); ok {
+			continue
+		}
+		valid := builtin
+		for _, e := range c.Environments {
+			valid = append(valid, e.Name)
+		}
+		return fmt.Errorf("source %q: unknown environment %q (want one of %s, or define it under environments)", s.Name, s.Environment, strings.Join(valid, ", "))
+	}
+	return nil
+}
+
+// RiskOf returns the risk level of a source's environment label. Labels are checked
+// when the config is loaded; unknown ones count as RiskNone.
+func (c Config) RiskOf(s Source) Risk {
+	label := s.Environment
+	if e, ok := c.environment(label); ok {
+		label = e.Risk
+	}
+	r, _ := ParseRisk(label)
+	return r
+}
+
+// ColorOf returns the display color for a source: its own color, then the color of its
+// custom environment, then the default color of its risk level. Empty means uncolored.
+func (c Config) ColorOf(s Source) string {
+	if s.Color != "" {
+		return s.Color
+	}
+	if e, ok := c.environment(s.Environment); ok && e.Color != "" {
+		return e.Color
+	}
+	return riskColors[c.RiskOf(s)]
+}
+
+// NeedsTypedConfirmation reports whether wiping dst must be confirmed by typing its
+// name rather than a yes/no answer.
+func (c Config) NeedsTypedConfirmation(dst Source) (bool, error) {
+	threshold, err := c.typingThreshold()
+	if err != nil {
+		return false, err
+	}
+	r := c.RiskOf(dst)
+	return r != RiskNone && r >= threshold, nil
+}
+
+// confirmByTyping returns confirm_by_typing, or its default.
+func (c Config) confirmByTyping() string {
+	if c.ConfirmByTyping == "" {
+		return DefaultConfirmByTyping
+	}
+	return c.ConfirmByTyping
+}
+
+// typingThreshold returns the risk level from which destinations must be confirmed
+// by typing their name.
+func (c Config) typingThreshold() (Risk, error) {
+	r, err := ParseRisk(c.confirmByTyping())
+	if err != nil {
+		return r, fmt.Errorf("confirm_by_typing: %w", err)
+	}
+	return r, nil
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckEnvironments(t *testing.T) {
+	envs := []Environment{{Name: "perf", Risk: "staging"}}
+	tests := []struct {
+		name    string
+		c       Config
+		wantErr string
+	}{
+		{
+			name: "built-in and custom labels",
+			c:    Config{Environments: envs, Sources: []Source{{Name: "a", Environment: "Prod"}, {Name: "b", Environment: "perf"}, {Name: "c"}}},
+		},
+		{
+			name:    "unknown label",
+			c:       Config{Environments: envs, Sources: []Source{{Name: "a", Environment: "prdo"}}},
+			wantErr: `source "a": unknown environment "prdo" (want one of dev, local, prod, production, qa, staging, test, perf`,
+		},
+		{
+			name:    "unknown risk",
+			c:       Config{Environments: []Environment{{Name: "perf", Risk: "high"}}},
+			wantErr: `environment "perf": unknown risk "high"`,
+		},
+		{
+			name:    "missing risk",
+			c:       Config{Environments: []Environment{{Name: "perf"}}},
+			wantErr: `environment "perf": unknown risk ""`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.c.checkEnvironments()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("checkEnvironments() = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("checkEnvironments() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRiskOf(t *testing.T) {
+	c := Config{Environments: []Environment{{Name: "perf", Risk: "staging"}}}
+	for label, want := range map[string]Risk{"": RiskNone, "dev": RiskLocal, "QA": RiskTest, "perf": RiskStaging, "production": RiskProduction} {
+		if got := c.RiskOf(Source{Environment: label}); got != want {
+			t.Errorf("RiskOf(%q) = %v, want %v", label, got, want)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package config
+
+import "gopkg.in/yaml.v3"
+
+// Globals copies the source server's roles, role memberships and, optionally,
+// tablespaces to a destination before the import. Superusers, replication roles
+// and passwords are left out unless allowed. A plain true or false turns it on or
+// off with the defaults.
+type Globals struct {
+	Enabled     bool     `yaml:"enabled"`
+	Roles       []string `yaml:"roles,omitempty"`       // glob patterns of roles to copy; empty copies every role
+	Superusers  bool     `yaml:"superusers,omitempty"`  // also copy superuser roles
+	Replication bool     `yaml:"replication,omitempty"` // also copy replication roles
+	Passwords   bool     `yaml:"passwords,omitempty"`   // copy role passwords (needs superuser on the source)
+	Tablespaces bool     `yaml:"tablespaces,omitempty"` // also create tablespaces; their locations must exist
+}
+
+func (g *Globals) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		return n.Decode(&g.Enabled)
+	}
+	type plain Globals
+	p := plain{Enabled: true}
+	if err := n.Decode(&p); err != nil {
+		return err
+	}
+	*g = Globals(p)
+	return nil
+}
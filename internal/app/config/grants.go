@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Grant lists the privileges a role gets on a restored schema and on the tables and
+// sequences in it, now and created later. A plain string picks a preset: read, write
+// or all.
+type Grant struct {
+	Schema    []string `yaml:"schema,omitempty"`    // USAGE, CREATE
+	Tables    []string `yaml:"tables,omitempty"`    // SELECT, INSERT, UPDATE, DELETE, TRUNCATE, REFERENCES, TRIGGER
+	Sequences []string `yaml:"sequences,omitempty"` // USAGE, SELECT, UPDATE
+}
+
+var grantPresets = map[string]Grant{
+	"read": {
+		Schema:    []string{"USAGE"},
+		Tables:    []string{"SELECT"},
+		Sequences: []string{"SELECT"},
+	},
+	"write": {
+		Schema:    []string{"USAGE"},
+		Tables:    []string{"SELECT", "INSERT", "UPDATE", "DELETE"},
+		Sequences: []string{"USAGE", "SELECT", "UPDATE"},
+	},
+	"all": {
+		Schema:    []string{"ALL"},
+		Tables:    []string{"ALL"},
+		Sequences: []string{"ALL"},
+	},
+}
+
+func (g *Grant) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		preset, ok := grantPresets[n.Value]
+		if !ok {
+			names := make([]string, 0, len(grantPresets))
+			for name := range grantPresets {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			return fmt.Errorf("line %d: unknown grant preset %q (want one of %s)", n.Line, n.Value, strings.Join(names, ", "))
+		}
+		*g = preset
+		return nil
+	}
+	type plain Grant
+	return n.Decode((*plain)(g))
+}
+
+// Roles returns every role a destination's owner, grants and role_map refer to on
+// the destination, sorted.
+func (s Source) Roles() []string {
+	var roles []string
+	add := func(r string) {
+		if r != "" && !slices.Contains(roles, r) {
+			roles = append(roles, r)
+		}
+	}
+	add(s.Owner)
+	for role := range s.Grants {
+		add(role)
+	}
+	if s.KeepOwners {
+		for _, role := range s.RoleMap {
+			add(role)
+		}
+	}
+	slices.Sort(roles)
+	return roles
+}
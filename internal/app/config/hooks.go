@@ -0,0 +1,53 @@
+package config
+
+import (
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultLogFile is where hook output is appended when log_file is not set.
+const DefaultLogFile = "psql-transporter.log"
+
+// Hooks are local shell commands run at fixed points of a transfer.
+type Hooks struct {
+	BeforeExport []Hook `yaml:"before_export,omitempty"`
+	AfterExport  []Hook `yaml:"after_export,omitempty"`
+	BeforeWipe   []Hook `yaml:"before_wipe,omitempty"`
+	AfterImport  []Hook `yaml:"after_import,omitempty"`
+	OnFailure    []Hook `yaml:"on_failure,omitempty"`
+}
+
+// Concat returns h with the hooks of more run after its own.
+func (h Hooks) Concat(more Hooks) Hooks {
+	return Hooks{
+		BeforeExport: slices.Concat(h.BeforeExport, more.BeforeExport),
+		AfterExport:  slices.Concat(h.AfterExport, more.AfterExport),
+		BeforeWipe:   slices.Concat(h.BeforeWipe, more.BeforeWipe),
+		AfterImport:  slices.Concat(h.AfterImport, more.AfterImport),
+		OnFailure:    slices.Concat(h.OnFailure, more.OnFailure),
+	}
+}
+
+// Hook is a shell command. A plain string is the command itself.
+type Hook struct {
+	Run             string `yaml:"run"`
+	ContinueOnError bool   `yaml:"continue_on_error,omitempty"`
+}
+
+func (h *Hook) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		h.Run = n.Value
+		return nil
+	}
+	type plain Hook
+	return n.Decode((*plain)(h))
+}
+
+// LogPath returns the file hook output is appended to.
+func (c Config) LogPath() string {
+	if c.LogFile != "" {
+		return c.LogFile
+	}
+	return DefaultLogFile
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LocalSuffix turns a config file name into its personal override file name,
+// e.g. psql-transporter.yaml -> psql-transporter.local.yaml.
+const LocalSuffix = ".local"
+
+// LocalPath returns the path of the git-ignored personal override for the config at path.
+func LocalPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + LocalSuffix + ext
+}
+
+// Layers returns every file that contributes to the config at path, lowest precedence
+// first: files listed under include: come before the file that includes them, and the
+// personal override (see LocalPath) comes last if it exists.
+func Layers(path string) ([]string, error) {
+	out, _, err := layers(path)
+	return out, err
+}
+
+// layers is Layers, also returning how many of the files are shared: the rest come
+// from the personal override.
+func layers(path string) ([]string, int, error) {
+	var out []string
+	if err := collectLayers(path, nil, &out); err != nil {
+		return nil, 0, err
+	}
+	shared := len(out)
+	local := LocalPath(path)
+	if _, err := os.Stat(local); err == nil {
+		if err := collectLayers(local, nil, &out); err != nil {
+			return nil, 0, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, 0, err
+	}
+	return out, shared, nil
+}
+
+// checkLocal makes sure the personal override at local, which turned shared into
+// merged, only tightens the safety settings of the shared config: it may not touch
+// policies, raise confirm_by_typing, or lower the risk of an environment or source.
+func checkLocal(shared, merged Config, local string) error {
+	if !reflect.DeepEqual(shared.Policies, merged.Policies) {
+		return fmt.Errorf("%s: policies can only be set in the shared config", local)
+	}
+	if merged.ConfirmByTyping != shared.ConfirmByTyping {
+		from, err := shared.typingThreshold()
+		if err != nil {
+			return err
+		}
+		to, err := merged.typingThreshold()
+		if err != nil {
+			return err
+		}
+		if to > from {
+			return fmt.Errorf("%s: confirm_by_typing may only be lowered, not raised from %s", local, shared.confirmByTyping())
+		}
+	}
+	for _, e := range shared.Environments {
+		m, _ := merged.environment(e.Name)
+		from, _ := ParseRisk(e.Risk)
+		if to, _ := ParseRisk(m.Risk); to < from {
+			return fmt.Errorf("%s: environment %q may not be lowered from risk %s to %s", local, e.Name, e.Risk, m.Risk)
+		}
+	}
+	for _, s := range shared.Sources {
+		for _, m := range merged.Sources {
+			if m.Name == s.Name && merged.RiskOf(m) < shared.RiskOf(s) {
+				return fmt.Errorf("%s: source %q may not be moved from environment %q to the lower-risk %q", local, s.Name, s.Environment, m.Environment)
+			}
+		}
+	}
+	return nil
+}
+
+func collectLayers(path string, stack []string, out *[]string) error {
+	for _, p := range stack {
+		if p == path {
+			return fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), path)
+		}
+	}
+	for _, p := range *out {
+		if p == path {
+			return nil // already merged through another include
+		}
+	}
+	var c struct {
+		Include []string `yaml:"include"`
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for _, inc := range c.Include {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(path), inc)
+		}
+		if err := collectLayers(inc, append(stack, path), out); err != nil {
+			return err
+		}
+	}
+	*out = append(*out, path)
+	return nil
+}
+
+// RedactedFile returns the contents of a single config file, without resolving
+// includes or overrides, with every password value replaced by a placeholder.
+func RedactedFile(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	redactNode(&doc)
+	return encodeNode(&doc)
+}
+
+func redactNode(n *yaml.Node) {
+	if n.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if v := n.Content[i+1]; n.Content[i].Value == "password" && v.Kind == yaml.ScalarNode && v.Value != "" {
+				v.Value, v.Style = redactedValue, 0
+			}
+		}
+	}
+	for _, c := range n.Content {
+		redactNode(c)
+	}
+}
+
+// mergeNodes merges the mapping over into base. Scalars and lists in over replace
+// those in base, nested mappings are merged, and lists whose items all carry a name
+// (such as sources) are merged item by item on that name. A protected: true in base
+// can never be switched off by a later layer.
+func mergeNodes(base, over *yaml.Node) {
+	for i := 0; i+1 < len(over.Content); i += 2 {
+		key, val := over.Content[i], over.Content[i+1]
+		if key.Value == "include" || key.Value == "version" {
+			continue
+		}
+		j := mappingIndex(base, key.Value)
+		if j < 0 {
+			base.Content = append(base.Content, key, val)
+			continue
+		}
+		cur := base.Content[j+1]
+		switch {
+		case key.Value == "protected" && isTrue(cur):
+			// protection is sticky across layers
+		case cur.Kind == yaml.MappingNode && val.Kind == yaml.MappingNode:
+			mergeNodes(cur, val)
+		case cur.Kind == yaml.SequenceNode && val.Kind == yaml.SequenceNode && namedItems(cur) && namedItems(val):
+			mergeNamed(cur, val)
+		default:
+			base.Content[j+1] = val
+		}
+	}
+}
+
+func mergeNamed(base, over *yaml.Node) {
+	for _, item := range over.Content {
+		name := mappingValue(item, "name")
+		merged := false
+		for _, cur := range base.Content {
+			if mappingValue(cur, "name") == name {
+				mergeNodes(cur, item)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			base.Content = append(base.Content, item)
+		}
+	}
+}
+
+func mappingIndex(m *yaml.Node, key string) int {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func mappingValue(m *yaml.Node, key string) string {
+	if i := mappingIndex(m, key); i >= 0 {
+		return m.Content[i+1].Value
+	}
+	return ""
+}
+
+func namedItems(seq *yaml.Node) bool {
+	for _, item := range seq.Content {
+		if item.Kind != yaml.MappingNode || mappingValue(item, "name") == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func isTrue(n *yaml.Node) bool {
+	var b bool
+	return n.Kind == yaml.ScalarNode && n.Decode(&b) == nil && b
+}
@@ -0,0 +1,241 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseRoot returns the top-level mapping of the YAML document s.
+func parseRoot(t *testing.T, s string) *yaml.Node {
+	t.Helper()
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(s), &doc); err != nil {
+		t.Fatal(err)
+	}
+	root, err := documentRoot(&doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestMergeNodes(t *testing.T) {
+	tests := []struct {
+		name       string
+		base, over string
+		want       string
+	}{
+		{
+			name: "scalars are replaced",
+			base: "a: 1\nb: 2\n",
+			over: "b: 3\nc: 4\n",
+			want: "a: 1\nb: 3\nc: 4\n",
+		},
+		{
+			name: "mappings are merged",
+			base: "encryption:\n  recipients: [x]\n",
+			over: "encryption:\n  identity_file: key.txt\n",
+			want: "encryption:\n  recipients: [x]\n  identity_file: key.txt\n",
+		},
+		{
+			name: "lists without names are replaced",
+			base: "users: [a, b]\n",
+			over: "users: [c]\n",
+			want: "users: [c]\n",
+		},
+		{
+			name: "named lists are merged by name",
+			base: "sources:\n  - name: dev\n    host: db\n    port: 5432\n  - name: prod\n    host: prod-db\n",
+			over: "sources:\n  - name: dev\n    port: 5433\n    password: secret\n  - name: scratch\n    host: localhost\n",
+			want: "sources:\n  - name: dev\n    host: db\n    port: 5433\n    password: secret\n  - name: prod\n    host: prod-db\n  - name: scratch\n    host: localhost\n",
+		},
+		{
+			name: "a named list replaces one that isn't",
+			base: "sources: [a]\n",
+			over: "sources:\n  - name: dev\n",
+			want: "sources:\n  - name: dev\n",
+		},
+		{
+			name: "protected is sticky",
+			base: "sources:\n  - name: prod\n    protected: true\n",
+			over: "sources:\n  - name: prod\n    protected: false\n",
+			want: "sources:\n  - name: prod\n    protected: true\n",
+		},
+		{
+			name: "protected can be switched on",
+			base: "sources:\n  - name: staging\n    protected: false\n",
+			over: "sources:\n  - name: staging\n    protected: true\n",
+			want: "sources:\n  - name: staging\n    protected: true\n",
+		},
+		{
+			name: "include and version are not merged",
+			base: "version: 1\n",
+			over: "version: 0\ninclude: [other.yaml]\n",
+			want: "version: 1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := parseRoot(t, tt.base)
+			mergeNodes(base, parseRoot(t, tt.over))
+			got, _ := yaml.Marshal(base)
+			want, _ := yaml.Marshal(parseRoot(t, tt.want))
+			if string(got) != string(want) {
+				t.Errorf("merged =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+// writeFiles writes each file of files under dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, body := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLayers(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		DefaultFile:                   "include: [shared/base.yaml, team.yaml]\n",
+		"shared/base.yaml":            "include: [common.yaml]\n",
+		"shared/common.yaml":          "",
+		"team.yaml":                   "include: [shared/common.yaml]\n",
+		"psql-transporter.local.yaml": "",
+	})
+	got, err := Layers(filepath.Join(dir, DefaultFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rel []string
+	for _, p := range got {
+		r, _ := filepath.Rel(dir, p)
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	want := []string{"shared/common.yaml", "shared/base.yaml", "team.yaml", DefaultFile, "psql-transporter.local.yaml"}
+	if !reflect.DeepEqual(rel, want) {
+		t.Errorf("Layers() = %v, want %v", rel, want)
+	}
+}
+
+func TestLayersCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		DefaultFile: "include: [a.yaml]\n",
+		"a.yaml":    "include: [b.yaml]\n",
+		"b.yaml":    "include: [a.yaml]\n",
+	})
+	_, err := Layers(filepath.Join(dir, DefaultFile))
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("Layers() error = %v, want an include cycle", err)
+	}
+}
+
+func TestLoadRawMergesLayers(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		DefaultFile:                   "include: [shared.yaml]\nsources:\n  - name: dev\n    host: localhost\n",
+		"shared.yaml":                 "sources:\n  - name: prod\n    host: prod-db\n    protected: true\n",
+		"psql-transporter.local.yaml": "sources:\n  - name: dev\n    password: secret\n  - name: prod\n    protected: false\n",
+	})
+	c, err := LoadRaw(filepath.Join(dir, DefaultFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Sources) != 2 {
+		t.Fatalf("got %d sources, want 2", len(c.Sources))
+	}
+	prod, dev := c.Sources[0], c.Sources[1]
+	if prod.Name != "prod" || !prod.Protected {
+		t.Errorf("prod = %+v, want it still protected", prod)
+	}
+	if dev.Name != "dev" || dev.Host != "localhost" || dev.Password != "secret" {
+		t.Errorf("dev = %+v, want the local password merged in", dev)
+	}
+}
+
+func TestLocalLayerMayOnlyTighten(t *testing.T) {
+	const shared = `sources:
+  - name: qa
+    environment: staging
+  - name: dev
+    environment: dev
+environments:
+  - name: perf
+    risk: staging
+confirm_by_typing: staging
+policies:
+  wipe_windows:
+    - destinations: [qa]
+      hours: "09:00-18:00"
+`
+	tests := []struct {
+		name    string
+		local   string
+		wantErr string
+	}{
+		{name: "credentials only", local: "sources:\n  - name: qa\n    password: secret\n"},
+		{name: "empty policies", local: "policies: {}\n"},
+		{name: "lower confirm_by_typing", local: "confirm_by_typing: test\n"},
+		{name: "raise a source", local: "sources:\n  - name: dev\n    environment: prod\n"},
+		{name: "add an environment", local: "environments:\n  - name: sandbox\n    risk: local\n"},
+		{
+			name:    "replace wipe windows",
+			local:   "policies:\n  wipe_windows: []\n",
+			wantErr: "policies can only be set in the shared config",
+		},
+		{
+			name:    "add a policy",
+			local:   "policies:\n  no_export: [qa]\n",
+			wantErr: "policies can only be set in the shared config",
+		},
+		{
+			name:    "raise confirm_by_typing",
+			local:   "confirm_by_typing: production\n",
+			wantErr: "confirm_by_typing may only be lowered",
+		},
+		{
+			name:    "lower an environment",
+			local:   "environments:\n  - name: perf\n    risk: local\n",
+			wantErr: `environment "perf" may not be lowered`,
+		},
+		{
+			name:    "lower a source",
+			local:   "sources:\n  - name: qa\n    environment: dev\n",
+			wantErr: `source "qa" may not be moved`,
+		},
+		{
+			name:    "shadow a built-in label",
+			local:   "environments:\n  - name: staging\n    risk: local\n",
+			wantErr: `source "qa" may not be moved`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{
+				DefaultFile:                   shared,
+				"psql-transporter.local.yaml": tt.local,
+			})
+			_, err := LoadRaw(filepath.Join(dir, DefaultFile))
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("LoadRaw() = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("LoadRaw() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
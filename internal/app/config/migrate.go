@@ -0,0 +1,164 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the config format version understood and written by this build.
+// Files without a version field are treated as version 0.
+const CurrentVersion = 1
+
+// migration upgrades the top-level mapping of a config document by exactly one version.
+type migration func(root *yaml.Node) error
+
+// migrations[i] upgrades a document from version i to version i+1.
+var migrations = []migration{
+	migrateV0ToV1,
+}
+
+// migrateV0ToV1 only introduces the version field itself, which upgrade stamps
+// after every step; the rest of the document is unchanged.
+func migrateV0ToV1(root *yaml.Node) error { return nil }
+
+// MigrationResult describes what Migrate did (or would do) to a config file.
+type MigrationResult struct {
+	Path   string
+	From   int
+	To     int
+	Backup string // path of the backup copy; empty on dry runs or when nothing changed
+	Before []byte
+	After  []byte
+}
+
+// Changed reports whether the file needed upgrading.
+func (r MigrationResult) Changed() bool { return r.From != r.To }
+
+// Diff returns a line diff between the original and upgraded file contents.
+func (r MigrationResult) Diff() string { return lineDiff(string(r.Before), string(r.After)) }
+
+// Migrate upgrades the config file at path to CurrentVersion. Unless dryRun is set,
+// the original file is copied to "<path>.v<from>.bak" before being rewritten in place.
+func Migrate(path string, dryRun bool) (MigrationResult, error) {
+	res := MigrationResult{Path: path}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return res, err
+	}
+	res.Before = b
+	res.After = b
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return res, fmt.Errorf("%s: %w", path, err)
+	}
+	root, err := documentRoot(&doc)
+	if err != nil {
+		return res, fmt.Errorf("%s: %w", path, err)
+	}
+	from, err := upgrade(root, path)
+	res.From, res.To = from, from
+	if err != nil {
+		return res, err
+	}
+	if from == CurrentVersion {
+		return res, nil
+	}
+	res.To = CurrentVersion
+
+	if res.After, err = encodeNode(&doc); err != nil {
+		return res, err
+	}
+	if dryRun {
+		return res, nil
+	}
+
+	res.Backup = fmt.Sprintf("%s.v%d.bak", path, from)
+	if err := os.WriteFile(res.Backup, b, 0600); err != nil {
+		return res, err
+	}
+	return res, os.WriteFile(path, res.After, 0600)
+}
+
+// upgrade brings the top-level mapping of the config file at path up to
+// CurrentVersion in memory and returns the version it was written for.
+func upgrade(root *yaml.Node, path string) (int, error) {
+	from, err := readVersion(root)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	if from > CurrentVersion {
+		return from, fmt.Errorf("%s is config version %d, but this build only understands up to version %d; upgrade psql-transporter", path, from, CurrentVersion)
+	}
+	for v := from; v < CurrentVersion; v++ {
+		if err := migrations[v](root); err != nil {
+			return from, fmt.Errorf("%s: migrating v%d to v%d: %w", path, v, v+1, err)
+		}
+		setVersion(root, v+1)
+	}
+	return from, nil
+}
+
+// encodeNode renders a YAML document the way hand-written config files are usually
+// laid out, with two-space indentation.
+func encodeNode(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// documentRoot returns the top-level mapping of doc, creating one for empty files.
+func documentRoot(doc *yaml.Node) (*yaml.Node, error) {
+	if doc.Kind == 0 {
+		doc.Kind = yaml.DocumentNode
+	}
+	if doc.Kind != yaml.DocumentNode {
+		return nil, errors.New("config is not a YAML document")
+	}
+	if len(doc.Content) == 0 {
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"})
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.New("config must be a YAML mapping")
+	}
+	return root, nil
+}
+
+func readVersion(root *yaml.Node) (int, error) {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "version" {
+			continue
+		}
+		var v int
+		if err := root.Content[i+1].Decode(&v); err != nil {
+			return 0, fmt.Errorf("invalid version: %w", err)
+		}
+		return v, nil
+	}
+	return 0, nil
+}
+
+// setVersion updates the version key, inserting it at the top of the mapping if absent.
+func setVersion(root *yaml.Node, v int) {
+	val := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: fmt.Sprintf("%d", v)}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "version" {
+			root.Content[i+1] = val
+			return
+		}
+	}
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"}
+	root.Content = append([]*yaml.Node{key, val}, root.Content...)
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const v0Config = "sources:\n  - name: dev\n    host: localhost\n"
+
+func TestLoadRawLeavesOldFilesAlone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultFile)
+	if err := os.WriteFile(path, []byte(v0Config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadRaw(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != CurrentVersion {
+		t.Errorf("Version = %d, want %d", c.Version, CurrentVersion)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != v0Config {
+		t.Errorf("LoadRaw rewrote the file:\n%s", b)
+	}
+	if _, err := os.Stat(path + ".v0.bak"); !os.IsNotExist(err) {
+		t.Errorf("LoadRaw wrote a backup: %v", err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultFile)
+	if err := os.WriteFile(path, []byte(v0Config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Migrate(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Changed() || res.From != 0 || res.To != CurrentVersion || res.Backup != "" {
+		t.Errorf("dry run = %+v", res)
+	}
+	if b, _ := os.ReadFile(path); string(b) != v0Config {
+		t.Errorf("dry run rewrote the file:\n%s", b)
+	}
+
+	if res, err = Migrate(path, false); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(res.Backup); string(b) != v0Config {
+		t.Errorf("backup = %q, want the original", b)
+	}
+	if res, err = Migrate(path, false); err != nil {
+		t.Fatal(err)
+	}
+	if res.Changed() {
+		t.Errorf("second run changed the file again: %+v", res)
+	}
+}
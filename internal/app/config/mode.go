@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Transfer modes.
+const (
+	ModeFull        = "full"        // wipe the destination schema and restore a full dump (default)
+	ModeDataOnly    = "data-only"   // keep the destination schema; truncate and reload table data
+	ModeMerge       = "merge"       // upsert selected tables by primary key, leaving everything else alone
+	ModeIncremental = "incremental" // upsert rows changed since the last sync, by watermark column
+)
+
+// Modes lists every transfer mode.
+var Modes = []string{ModeFull, ModeDataOnly, ModeMerge, ModeIncremental}
+
+// Merge configures merge mode for a destination.
+type Merge struct {
+	Tables        []string `yaml:"tables"`                   // tables to merge, e.g. countries or billing.plans
+	DeleteMissing bool     `yaml:"delete_missing,omitempty"` // delete destination rows whose key is not in the source
+}
+
+// Where incremental sync keeps its watermarks.
+const (
+	StateInFile  = "file"  // the local state file (default)
+	StateInTable = "table" // a metadata table on the destination
+)
+
+// Incremental configures incremental sync for a destination.
+type Incremental struct {
+	Tables []Watermark `yaml:"tables"`
+	State  string      `yaml:"state,omitempty"` // file (default) or table
+}
+
+// Watermark names a table and the column that tells which of its rows changed: a
+// timestamp such as updated_at or an ever-increasing id.
+type Watermark struct {
+	Table  string `yaml:"table"`
+	Column string `yaml:"watermark"`
+}
+
+// ParseMode validates a transfer mode name; empty means ModeFull.
+func ParseMode(m string) (string, error) {
+	if m == "" {
+		return ModeFull, nil
+	}
+	if !slices.Contains(Modes, m) {
+		return "", fmt.Errorf("unknown mode %q (want one of %s)", m, strings.Join(Modes, ", "))
+	}
+	return m, nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import "errors"
+
+// Policies declares which transfers may run. Names in rules match source names;
+// "*" matches any name and "file" stands for a dump file (as source or destination).
+type Policies struct {
+	NoExport       []string     `yaml:"no_export,omitempty"`       // sources that may never be dumped
+	AllowedPairs   []PairRule   `yaml:"allowed_pairs,omitempty"`   // if set, only these source→destination pairs may run
+	RequireMasking []PairRule   `yaml:"require_masking,omitempty"` // pairs that may only run with masked data; rejected until masking exists
+	WipeWindows    []WipeWindow `yaml:"wipe_windows,omitempty"`    // when matching destinations may be wiped
+	Users          []UserRule   `yaml:"users,omitempty"`           // if set, which OS users may run which transfers
+}
+
+// PairRule matches transfers from any of From to any of To.
+type PairRule struct {
+	From []string `yaml:"from"`
+	To   []string `yaml:"to"`
+}
+
+// WipeWindow allows wiping Destinations only on Days (mon, tue, ... or ranges like
+// mon-fri) between Hours (e.g. "09:00-18:00", local time). Empty Days or Hours mean
+// any day or any time. A destination covered by several windows may be wiped in any of them.
+type WipeWindow struct {
+	Destinations []string `yaml:"destinations"`
+	Days         []string `yaml:"days,omitempty"`
+	Hours        string   `yaml:"hours,omitempty"`
+}
+
+// UserRule allows the OS Users to transfer from Sources to Destinations.
+type UserRule struct {
+	Users        []string `yaml:"users"`
+	Sources      []string `yaml:"sources"`
+	Destinations []string `yaml:"destinations"`
+}
+
+// check rejects policies that can't be honoured.
+func (p Policies) check() error {
+	if len(p.RequireMasking) > 0 {
+		return errors.New("policies.require_masking: psql-transporter does not mask data yet, so these pairs could never run; block them with no_export or allowed_pairs instead")
+	}
+	return nil
+}
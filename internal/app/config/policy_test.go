@@ -0,0 +1,13 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRequireMaskingIsRejected(t *testing.T) {
+	p := Policies{RequireMasking: []PairRule{{From: []string{"prod"}, To: []string{"*"}}}}
+	if err := p.check(); err == nil || !strings.Contains(err.Error(), "does not mask data") {
+		t.Errorf("check() = %v, want masking to be rejected", err)
+	}
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Recipe is a named transfer that `run <name>` repeats: where the data comes from,
+// which destinations it goes to, how, and what runs around it. Its hooks, post_import,
+// maintenance and assert come on top of each destination's own.
+type Recipe struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+
+	Source string   `yaml:"source,omitempty"` // a configured source
+	File   string   `yaml:"file,omitempty"`   // or a dump file to load
+	To     []string `yaml:"to"`               // destination(s); a plain name for one
+
+	Mode          string   `yaml:"mode,omitempty"`           // one of Modes; defaults to full
+	Tables        []string `yaml:"tables,omitempty"`         // merge mode: tables to merge
+	DeleteMissing bool     `yaml:"delete_missing,omitempty"` // merge mode: delete rows missing from the source
+	Full          bool     `yaml:"full,omitempty"`           // incremental mode: resync every row
+	IntoSchema    string   `yaml:"into_schema,omitempty"`    // copy public into this schema instead
+	AllDatabases  bool     `yaml:"all_databases,omitempty"`  // copy every database of a server-level source
+	Databases     []string `yaml:"databases,omitempty"`      // copy these databases of a server-level source
+	Parallel      int      `yaml:"parallel,omitempty"`       // destinations loaded at a time
+	Wait          bool     `yaml:"wait,omitempty"`           // queue behind a run holding a destination's lock
+
+	Hooks       Hooks     `yaml:"hooks,omitempty"`
+	PostImport  []SQLStep `yaml:"post_import,omitempty"`
+	Maintenance []string  `yaml:"maintenance,omitempty"`
+	Assert      []string  `yaml:"assert,omitempty"`
+}
+
+func (r *Recipe) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if v := n.Content[i+1]; n.Content[i].Value == "to" && v.Kind == yaml.ScalarNode {
+				n.Content[i+1] = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{v}}
+			}
+		}
+	}
+	type plain Recipe
+	return n.Decode((*plain)(r))
+}
+
+// Recipe returns the recipe called name.
+func (c Config) Recipe(name string) (Recipe, error) {
+	for _, r := range c.Recipes {
+		if r.Name == name {
+			return r, nil
+		}
+	}
+	names := make([]string, len(c.Recipes))
+	for i, r := range c.Recipes {
+		names[i] = r.Name
+	}
+	if len(names) == 0 {
+		return Recipe{}, fmt.Errorf("no recipe named %q; the config has no recipes", name)
+	}
+	return Recipe{}, fmt.Errorf("no recipe named %q (want one of %s)", name, strings.Join(names, ", "))
+}
+
+// Check reports what makes r unusable with c: a missing source or destination, or
+// an unknown mode.
+func (r Recipe) Check(c Config) error {
+	known := func(name string) bool {
+		return slices.ContainsFunc(c.Sources, func(s Source) bool { return s.Name == name })
+	}
+	switch {
+	case r.Source == "" && r.File == "":
+		return fmt.Errorf("recipe %q: needs a source or a file", r.Name)
+	case r.Source != "" && r.File != "":
+		return fmt.Errorf("recipe %q: set either source or file, not both", r.Name)
+	case r.Source != "" && !known(r.Source):
+		return fmt.Errorf("recipe %q: no source named %q", r.Name, r.Source)
+	case len(r.To) == 0:
+		return fmt.Errorf("recipe %q: needs at least one destination in to", r.Name)
+	}
+	for _, name := range r.To {
+		if !known(name) {
+			return fmt.Errorf("recipe %q: no destination named %q", r.Name, name)
+		}
+	}
+	if _, err := ParseMode(r.Mode); err != nil {
+		return fmt.Errorf("recipe %q: %w", r.Name, err)
+	}
+	return nil
+}
+
+// Apply returns c with the recipe's settings added to its sources: its hooks after
+// those of the recipe's source (or of each destination when loading a file), and its
+// post-import SQL, maintenance tasks and assertions after those of each destination.
+func (r Recipe) Apply(c Config) Config {
+	c.Sources = slices.Clone(c.Sources)
+	for i, s := range c.Sources {
+		if s.Name == r.Source || r.File != "" && slices.Contains(r.To, s.Name) {
+			s.Hooks = s.Hooks.Concat(r.Hooks)
+		}
+		if slices.Contains(r.To, s.Name) {
+			s = r.applyTo(s)
+		}
+		c.Sources[i] = s
+	}
+	return c
+}
+
+func (r Recipe) applyTo(dst Source) Source {
+	dst.PostImport = slices.Concat(dst.PostImport, r.PostImport)
+	for _, task := range r.Maintenance {
+		if !slices.Contains(dst.Maintenance, task) {
+			dst.Maintenance = append(slices.Clip(dst.Maintenance), task)
+		}
+	}
+	dst.Assert = slices.Concat(dst.Assert, r.Assert)
+	return dst
+}
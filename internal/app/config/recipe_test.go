@@ -0,0 +1,85 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestRecipeTo(t *testing.T) {
+	var c struct {
+		Recipes []Recipe `yaml:"recipes"`
+	}
+	src := "recipes:\n  - name: one\n    to: dev\n  - name: many\n    to: [dev, qa]\n"
+	if err := yaml.Unmarshal([]byte(src), &c); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Recipes[0].To; !reflect.DeepEqual(got, []string{"dev"}) {
+		t.Errorf("to: dev = %q, want [dev]", got)
+	}
+	if got := c.Recipes[1].To; !reflect.DeepEqual(got, []string{"dev", "qa"}) {
+		t.Errorf("to: [dev, qa] = %q", got)
+	}
+}
+
+func TestRecipeCheck(t *testing.T) {
+	c := Config{Sources: []Source{{Name: "staging"}, {Name: "dev"}}}
+	tests := []struct {
+		name    string
+		r       Recipe
+		wantErr string
+	}{
+		{"ok", Recipe{Name: "r", Source: "staging", To: []string{"dev"}}, ""},
+		{"file", Recipe{Name: "r", File: "dump.sql", To: []string{"dev"}, Mode: ModeDataOnly}, ""},
+		{"no source", Recipe{Name: "r", To: []string{"dev"}}, "needs a source or a file"},
+		{"both", Recipe{Name: "r", Source: "staging", File: "dump.sql", To: []string{"dev"}}, "not both"},
+		{"unknown source", Recipe{Name: "r", Source: "prod", To: []string{"dev"}}, `no source named "prod"`},
+		{"no destination", Recipe{Name: "r", Source: "staging"}, "at least one destination"},
+		{"unknown destination", Recipe{Name: "r", Source: "staging", To: []string{"qa"}}, `no destination named "qa"`},
+		{"unknown mode", Recipe{Name: "r", Source: "staging", To: []string{"dev"}, Mode: "fast"}, `unknown mode "fast"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.r.Check(c)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Check() = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Check() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRecipeApply(t *testing.T) {
+	c := Config{Sources: []Source{
+		{Name: "staging", Hooks: Hooks{BeforeExport: []Hook{{Run: "a"}}}},
+		{Name: "dev", Maintenance: []string{"analyze"}, Assert: []string{"SELECT true"}},
+		{Name: "qa"},
+	}}
+	r := Recipe{
+		Source:      "staging",
+		To:          []string{"dev"},
+		Hooks:       Hooks{BeforeExport: []Hook{{Run: "b"}}},
+		Maintenance: []string{"analyze", "reindex"},
+		Assert:      []string{"SELECT 1"},
+	}
+	got := r.Apply(c)
+	if hooks := got.Sources[0].Hooks.BeforeExport; len(hooks) != 2 || hooks[0].Run != "a" || hooks[1].Run != "b" {
+		t.Errorf("source hooks = %+v, want the recipe's after the source's", hooks)
+	}
+	if m := got.Sources[1].Maintenance; !reflect.DeepEqual(m, []string{"analyze", "reindex"}) {
+		t.Errorf("dev maintenance = %q", m)
+	}
+	if a := got.Sources[1].Assert; !reflect.DeepEqual(a, []string{"SELECT true", "SELECT 1"}) {
+		t.Errorf("dev assert = %q", a)
+	}
+	if !reflect.DeepEqual(got.Sources[2], c.Sources[2]) {
+		t.Errorf("qa = %+v, want it untouched", got.Sources[2])
+	}
+	if len(c.Sources[0].Hooks.BeforeExport) != 1 || len(c.Sources[1].Maintenance) != 1 {
+		t.Errorf("Apply changed the original config: %+v", c.Sources)
+	}
+}
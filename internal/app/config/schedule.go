@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Schedule runs a recipe, or a transfer described in place, whenever its cron
+// expression comes due while `daemon` is running.
+type Schedule struct {
+	Name     string  `yaml:"name"`
+	Cron     string  `yaml:"cron"`               // five fields, or a descriptor such as @daily
+	Recipe   string  `yaml:"recipe,omitempty"`   // a configured recipe
+	Transfer *Recipe `yaml:"transfer,omitempty"` // or the transfer itself, written like a recipe without a name
+}
+
+// ScheduleRun is what the state file remembers about a schedule's last run.
+type ScheduleRun struct {
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitzero"`
+	Status   string    `json:"status"` // RunRunning, RunSucceeded or RunFailed
+	Error    string    `json:"error,omitempty"`
+	Skipped  time.Time `json:"last_skipped,omitzero"` // last time it came due while another run was going on
+}
+
+// Statuses of a ScheduleRun.
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+// Run returns the recipe s runs. A transfer described in place is named after s.
+func (s Schedule) Run(c Config) (Recipe, error) {
+	switch {
+	case s.Recipe != "" && s.Transfer != nil:
+		return Recipe{}, fmt.Errorf("schedule %q: set either recipe or transfer, not both", s.Name)
+	case s.Recipe != "":
+		r, err := c.Recipe(s.Recipe)
+		if err != nil {
+			return r, fmt.Errorf("schedule %q: %w", s.Name, err)
+		}
+		return r, r.Check(c)
+	case s.Transfer != nil:
+		r := *s.Transfer
+		r.Name = s.Name
+		return r, r.Check(c)
+	}
+	return Recipe{}, fmt.Errorf("schedule %q: needs a recipe or a transfer", s.Name)
+}
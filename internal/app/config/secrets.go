@@ -0,0 +1,222 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"gopkg.in/yaml.v3"
+)
+
+// EncryptedPrefix marks a password value that holds base64-encoded age ciphertext.
+const EncryptedPrefix = "age:"
+
+// IdentityEnv overrides the age identity file used to decrypt passwords.
+const IdentityEnv = "PSQL_TRANSPORTER_AGE_IDENTITY"
+
+// Encryption configures age encryption of password values in the config file.
+type Encryption struct {
+	Recipients   []string `yaml:"recipients,omitempty"`    // age public keys that can decrypt
+	IdentityFile string   `yaml:"identity_file,omitempty"` // private key file, relative to the file that sets it; usually set in the local override
+}
+
+// IsEncrypted reports whether a config value was written by EncryptFile.
+func IsEncrypted(v string) bool { return strings.HasPrefix(v, EncryptedPrefix) }
+
+// IdentityPath returns the age identity file to decrypt with: $PSQL_TRANSPORTER_AGE_IDENTITY,
+// then encryption.identity_file, then psql-transporter/age-identity.txt in the user config dir.
+func IdentityPath(c Config) (string, error) {
+	if p := os.Getenv(IdentityEnv); p != "" {
+		return p, nil
+	}
+	if p := c.Encryption.IdentityFile; p != "" {
+		if rest, ok := strings.CutPrefix(p, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			p = filepath.Join(home, rest)
+		}
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "psql-transporter", "age-identity.txt"), nil
+}
+
+// resolveIdentityFile makes a relative encryption.identity_file in root, the top-level
+// mapping of the config file at path, relative to the directory of that file.
+func resolveIdentityFile(root *yaml.Node, path string) {
+	i := mappingIndex(root, "encryption")
+	if i < 0 || root.Content[i+1].Kind != yaml.MappingNode {
+		return
+	}
+	enc := root.Content[i+1]
+	j := mappingIndex(enc, "identity_file")
+	if j < 0 {
+		return
+	}
+	v := enc.Content[j+1]
+	if v.Kind != yaml.ScalarNode || v.Value == "" || filepath.IsAbs(v.Value) || strings.HasPrefix(v.Value, "~/") {
+		return
+	}
+	v.Value = filepath.Join(filepath.Dir(path), v.Value)
+}
+
+// EncryptFile encrypts every plaintext password value in the config file at path for
+// the given age recipients, leaving the rest of the file untouched, and returns how
+// many values were encrypted.
+func EncryptFile(path string, recipients []string) (int, error) {
+	if len(recipients) == 0 {
+		return 0, errors.New("no age recipients configured (set encryption.recipients)")
+	}
+	rs, err := age.ParseRecipients(strings.NewReader(strings.Join(recipients, "\n")))
+	if err != nil {
+		return 0, err
+	}
+	return rewritePasswords(path, func(v string) (string, bool, error) {
+		if v == "" || IsEncrypted(v) {
+			return v, false, nil
+		}
+		out, err := encryptValue(v, rs)
+		return out, true, err
+	})
+}
+
+// DecryptFile turns every encrypted password value in the config file at path back
+// into plaintext and returns how many values were decrypted.
+func DecryptFile(path, identityFile string) (int, error) {
+	ids, err := readIdentities(identityFile)
+	if err != nil {
+		return 0, err
+	}
+	return rewritePasswords(path, func(v string) (string, bool, error) {
+		if !IsEncrypted(v) {
+			return v, false, nil
+		}
+		out, err := decryptValue(v, ids)
+		return out, true, err
+	})
+}
+
+// decryptSecrets decrypts encrypted source passwords in place. The identity file is
+// only read when at least one value is encrypted.
+func (c *Config) decryptSecrets() error {
+	var ids []age.Identity
+	for i := range c.Sources {
+		s := &c.Sources[i]
+		if !IsEncrypted(s.Password) {
+			continue
+		}
+		if ids == nil {
+			path, err := IdentityPath(*c)
+			if err != nil {
+				return err
+			}
+			if ids, err = readIdentities(path); err != nil {
+				return fmt.Errorf("password for source %q is encrypted: %w", s.Name, err)
+			}
+		}
+		pw, err := decryptValue(s.Password, ids)
+		if err != nil {
+			return fmt.Errorf("password for source %q: %w", s.Name, err)
+		}
+		s.Password = pw
+	}
+	return nil
+}
+
+func readIdentities(path string) ([]age.Identity, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading age identity: %w", err)
+	}
+	defer f.Close()
+	return age.ParseIdentities(f)
+}
+
+func encryptValue(v string, rs []age.Recipient) (string, error) {
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, rs...)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.WriteString(w, v); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return EncryptedPrefix + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func decryptValue(v string, ids []age.Identity) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(v, EncryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	r, err := age.Decrypt(bytes.NewReader(b), ids...)
+	if err != nil {
+		return "", err
+	}
+	out, err := io.ReadAll(r)
+	return string(out), err
+}
+
+// rewritePasswords applies fn to every password value in the file at path and writes
+// the file back if any value changed. Comments and layout are preserved.
+func rewritePasswords(path string, fn func(string) (string, bool, error)) (int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	n := 0
+	var walk func(*yaml.Node) error
+	walk = func(node *yaml.Node) error {
+		if node.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				v := node.Content[i+1]
+				if node.Content[i].Value != "password" || v.Kind != yaml.ScalarNode {
+					continue
+				}
+				out, changed, err := fn(v.Value)
+				if err != nil {
+					return fmt.Errorf("%s line %d: %w", path, v.Line, err)
+				}
+				if changed {
+					v.Value, v.Style = out, yaml.DoubleQuotedStyle
+					n++
+				}
+			}
+		}
+		for _, c := range node.Content {
+			if err := walk(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(&doc); err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	out, err := encodeNode(&doc)
+	if err != nil {
+		return 0, err
+	}
+	return n, os.WriteFile(path, out, 0600)
+}
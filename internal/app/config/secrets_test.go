@@ -0,0 +1,34 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestIdentityFileRelativeToLayer(t *testing.T) {
+	tests := []struct {
+		name    string
+		setting string
+		want    func(dir string) string
+	}{
+		{"relative", "keys/age.txt", func(dir string) string { return filepath.Join(dir, "team", "keys", "age.txt") }},
+		{"absolute", "/etc/age.txt", func(string) string { return "/etc/age.txt" }},
+		{"home", "~/age.txt", func(string) string { return "~/age.txt" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{
+				DefaultFile:        "include: [team/shared.yaml]\nsources:\n  - name: dev\n",
+				"team/shared.yaml": "encryption:\n  identity_file: " + tt.setting + "\n",
+			})
+			c, err := LoadRaw(filepath.Join(dir, DefaultFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := c.Encryption.IdentityFile, tt.want(dir); got != want {
+				t.Errorf("IdentityFile = %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package config
+
+// DefaultMaintenanceDB is the database a server-level source connects to when it
+// has no dbname.
+const DefaultMaintenanceDB = "postgres"
+
+// MaintenanceDB returns the database to connect to for server-wide work such as
+// listing or creating databases on a server-level source.
+func (s Source) MaintenanceDB() string {
+	if s.DBName == "" {
+		return DefaultMaintenanceDB
+	}
+	return s.DBName
+}
+
+// Database returns s pointed at the database called name on the same server. Its
+// name is "<source>/<database>", which is also what policies and hooks see.
+func (s Source) Database(name string) Source {
+	db := s
+	db.Name = s.Name + "/" + name
+	db.DBName = name
+	db.Server = false
+	return db
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// DefaultStateFile is where state kept between runs is saved when state_file is not set.
+const DefaultStateFile = "psql-transporter.state.json"
+
+// State is what psql-transporter remembers between runs.
+type State struct {
+	// Watermarks holds the last synced watermark of each table, keyed by
+	// SyncKey(source, destination) and then by table.
+	Watermarks map[string]map[string]string `json:"watermarks,omitempty"`
+	// Schedules holds the last run of each schedule, keyed by its name.
+	Schedules map[string]ScheduleRun `json:"schedules,omitempty"`
+}
+
+// SyncKey identifies a source and destination pair in State.
+func SyncKey(source, destination string) string { return source + " -> " + destination }
+
+// StatePath returns the file state is kept in.
+func (c Config) StatePath() string {
+	if c.StateFile != "" {
+		return c.StateFile
+	}
+	return DefaultStateFile
+}
+
+// LoadState reads the state file at path. A missing file is an empty state.
+func LoadState(path string) (State, error) {
+	var st State
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	if err := json.Unmarshal(b, &st); err != nil {
+		return st, fmt.Errorf("%s: %w", path, err)
+	}
+	return st, nil
+}
+
+// SaveState writes st to path, replacing the file atomically.
+func SaveState(path string, st State) error {
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// UpdateState loads the state file at path, applies update and saves it, holding an
+// exclusive lock on "<path>.lock" throughout so that writers in other goroutines or
+// processes don't overwrite each other's changes.
+func UpdateState(path string, update func(*State)) error {
+	lock, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("%s: %w", lock.Name(), err)
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+	st, err := LoadState(path)
+	if err != nil {
+		return err
+	}
+	update(&st)
+	return SaveState(path, st)
+}
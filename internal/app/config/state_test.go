@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestUpdateStateConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := UpdateState(path, func(st *State) {
+				if i%2 == 0 {
+					if st.Schedules == nil {
+						st.Schedules = make(map[string]ScheduleRun)
+					}
+					st.Schedules[fmt.Sprint(i)] = ScheduleRun{Status: RunSucceeded}
+					return
+				}
+				if st.Watermarks == nil {
+					st.Watermarks = make(map[string]map[string]string)
+				}
+				st.Watermarks[fmt.Sprint(i)] = map[string]string{"orders": "1"}
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	st, err := LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Schedules) != 10 || len(st.Watermarks) != 10 {
+		t.Errorf("got %d schedules and %d watermarks, want 10 of each", len(st.Schedules), len(st.Watermarks))
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	st, err := LoadState(path)
+	if err != nil || st.Watermarks != nil || st.Schedules != nil {
+		t.Fatalf("LoadState() of a missing file = %+v, %v, want an empty state", st, err)
+	}
+	key := SyncKey("staging", "dev")
+	want := State{Watermarks: map[string]map[string]string{key: {"orders": "2026-10-01 02:00:00", "public.users": "97"}}}
+	if err := SaveState(path, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadState() = %+v, want %+v", got, want)
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -19,10 +20,94 @@ type Source struct {
 	DBName    string `yaml:"dbname"`
 	SSLMode   string `yaml:"sslmode"`
 	Protected bool   `yaml:"protected"`
+	Server    bool   `yaml:"server,omitempty"` // a whole server; dbname is only used to connect, see MaintenanceDB
+
+	Environment string `yaml:"environment,omitempty"` // e.g. dev, staging, prod; see Environment
+	Color       string `yaml:"color,omitempty"`       // overrides the environment's display color
+	Hooks       Hooks  `yaml:"hooks,omitempty"`       // run whenever this source is either side of a transfer
+
+	// Destination settings
+	KeepTables  []KeepTable       `yaml:"keep_tables,omitempty"` // tables that survive a refresh of this destination
+	PostImport  []SQLStep         `yaml:"post_import,omitempty"` // SQL run after the import, in order
+	Assert      []string          `yaml:"assert,omitempty"`      // queries that must return true or a row after post_import
+	Migrate     *Migrations       `yaml:"migrate,omitempty"`     // the project's migration tool, run right after the import
+	Maintenance []string          `yaml:"maintenance,omitempty"` // analyze, vacuum_analyze, refresh_matviews, fix_sequences, reindex
+	Merge       *Merge            `yaml:"merge,omitempty"`       // tables to upsert in merge mode
+	Incremental *Incremental      `yaml:"incremental,omitempty"` // tables and watermarks for incremental mode
+	Owner       string            `yaml:"owner,omitempty"`       // role that owns the restored objects
+	Grants      map[string]Grant  `yaml:"grants,omitempty"`      // privileges per role, applied after the import
+	KeepOwners  bool              `yaml:"keep_owners,omitempty"` // keep the source's owners and privileges
+	RoleMap     map[string]string `yaml:"role_map,omitempty"`    // source role -> destination role, with keep_owners
+	Globals     Globals           `yaml:"globals,omitempty"`     // copy roles and tablespaces before the import
+}
+
+// Migrations runs the project's migration tool against a destination after the import.
+// The command runs with sh and gets PGHOST, PGPORT, PGUSER, PGPASSWORD, PGDATABASE,
+// PGSSLMODE and DATABASE_URL for the destination.
+type Migrations struct {
+	Command string `yaml:"command"`
+	Table   string `yaml:"table,omitempty"`  // applied-versions table; defaults to schema_migrations
+	Column  string `yaml:"column,omitempty"` // version column; defaults to version
+}
+
+// VersionTable returns the table and column that record applied migrations.
+func (m Migrations) VersionTable() (table, column string) {
+	table, column = m.Table, m.Column
+	if table == "" {
+		table = "schema_migrations"
+	}
+	if column == "" {
+		column = "version"
+	}
+	return table, column
+}
+
+// SQLStep is inline SQL or a SQL file to run against a destination. A plain string
+// is inline SQL.
+type SQLStep struct {
+	SQL  string `yaml:"sql,omitempty"`
+	File string `yaml:"file,omitempty"`
+}
+
+func (s *SQLStep) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		s.SQL = n.Value
+		return nil
+	}
+	type plain SQLStep
+	return n.Decode((*plain)(s))
+}
+
+// KeepTable is a destination table whose rows are saved before the wipe and put back
+// after the import. It may be written as a plain table name, which defaults to upsert.
+type KeepTable struct {
+	Table string `yaml:"table"`          // "users" (public schema) or "schema.table"
+	Mode  string `yaml:"mode,omitempty"` // upsert (default) or replace
+}
+
+func (k *KeepTable) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		k.Table = n.Value
+		return nil
+	}
+	type plain KeepTable
+	return n.Decode((*plain)(k))
 }
 
 type Config struct {
-	Sources []Source `yaml:"sources"`
+	Version    int        `yaml:"version"`
+	Include    []string   `yaml:"include,omitempty"`
+	Encryption Encryption `yaml:"encryption,omitempty"`
+	Sources    []Source   `yaml:"sources"`
+	Recipes    []Recipe   `yaml:"recipes,omitempty"`
+	Schedules  []Schedule `yaml:"schedules,omitempty"`
+
+	Environments    []Environment `yaml:"environments,omitempty"`
+	ConfirmByTyping string        `yaml:"confirm_by_typing,omitempty"` // risk level from which the destination name must be typed
+	Policies        Policies      `yaml:"policies,omitempty"`
+	Hooks           Hooks         `yaml:"hooks,omitempty"`      // run for every transfer
+	LogFile         string        `yaml:"log_file,omitempty"`   // defaults to DefaultLogFile
+	StateFile       string        `yaml:"state_file,omitempty"` // defaults to DefaultStateFile
 }
 
 func EnsureExists(root string) (string, bool, error) {
@@ -30,6 +115,7 @@ func EnsureExists(root string) (string, bool, error) {
 	_, err := os.Stat(cfgPath)
 	if errors.Is(err, os.ErrNotExist) {
 		def := Config{
+			Version: CurrentVersion,
 			Sources: []Source{
 				{
 					Name: "example",
@@ -48,21 +134,91 @@ func EnsureExists(root string) (string, bool, error) {
 	return cfgPath, false, err
 }
 
+// Load reads the config at path together with its included files and personal
+// override (see Layers), upgrading any file written for an older config version
+// in memory, and returns the merged result with encrypted passwords decrypted.
 func Load(path string) (Config, error) {
+	c, err := LoadRaw(path)
+	if err != nil {
+		return c, err
+	}
+	if err := c.decryptSecrets(); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// LoadRaw is like Load but leaves encrypted passwords as they are, so it works
+// without access to the age identity.
+func LoadRaw(path string) (Config, error) {
 	var c Config
-	b, err := os.ReadFile(path)
+	files, shared, err := layers(path)
 	if err != nil {
 		return c, err
 	}
-	if err := yaml.Unmarshal(b, &c); err != nil {
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	var base Config
+	for i, layer := range files {
+		if i == shared {
+			if err := merged.Decode(&base); err != nil {
+				return c, err
+			}
+		}
+		b, err := os.ReadFile(layer)
+		if err != nil {
+			return c, err
+		}
+		var doc yaml.Node
+		if err := yaml.Unmarshal(b, &doc); err != nil {
+			return c, fmt.Errorf("%s: %w", layer, err)
+		}
+		root, err := documentRoot(&doc)
+		if err != nil {
+			return c, fmt.Errorf("%s: %w", layer, err)
+		}
+		if _, err := upgrade(root, layer); err != nil {
+			return c, err
+		}
+		resolveIdentityFile(root, layer)
+		mergeNodes(merged, root)
+	}
+	if err := merged.Decode(&c); err != nil {
 		return c, err
 	}
+	c.Version = CurrentVersion
 	if len(c.Sources) == 0 {
 		return c, errors.New("config has no sources")
 	}
+	if err := c.checkEnvironments(); err != nil {
+		return c, err
+	}
+	if shared < len(files) {
+		if err := checkLocal(base, c, LocalPath(path)); err != nil {
+			return c, err
+		}
+	}
+	if err := c.Policies.check(); err != nil {
+		return c, err
+	}
 	return c, nil
 }
 
+// Redacted returns a copy of c with every password replaced by a placeholder,
+// suitable for printing.
+func (c Config) Redacted() Config {
+	out := c
+	out.Sources = make([]Source, len(c.Sources))
+	for i, s := range c.Sources {
+		if s.Password != "" {
+			s.Password = redactedValue
+		}
+		out.Sources[i] = s
+	}
+	return out
+}
+
+const redactedValue = "********"
+
 func Save(path string, c Config) error {
 	b, err := yaml.Marshal(c)
 	if err != nil {
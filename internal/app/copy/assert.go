@@ -0,0 +1,36 @@
+package copy
+
+import (
+	"context"
+	"os"
+	"strings"
+)
+
+// ExecFile runs the SQL script in file against c, stopping at the first error.
+func ExecFile(ctx context.Context, c Conn, file string) error {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	_, err = Query(ctx, c, string(b))
+	return err
+}
+
+// Assert runs query against c and reports whether it holds: the query must return at
+// least one row, and the first value of the first row must not be false or NULL.
+// result is the first row as returned, for reporting.
+func Assert(ctx context.Context, c Conn, query string) (ok bool, result string, err error) {
+	rows, err := Query(ctx, c, query)
+	if err != nil {
+		return false, "", err
+	}
+	if len(rows) == 0 {
+		return false, "(no rows)", nil
+	}
+	first := rows[0]
+	result = strings.Join(first, " | ")
+	if first[0] == "" {
+		result = "NULL"
+	}
+	return first[0] != "f" && first[0] != "", result, nil
+}
@@ -0,0 +1,148 @@
+package copy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// WipeSchema is the schema Wipe drops and recreates.
+const WipeSchema = "public"
+
+// Psql's unaligned output uses these as field and record separators so that values
+// containing tabs or newlines don't break parsing.
+const (
+	fieldSep  = "\x1f"
+	recordSep = "\x1e"
+)
+
+// Query runs SQL with psql and returns the rows of every result, each split into its
+// column values. NULLs come back as empty strings. sql may hold several statements and
+// psql meta-commands such as \copy; execution stops at the first error. psql ends each
+// result with a newline, so values that contain newlines are split across rows.
+func Query(ctx context.Context, c Conn, sql string) ([][]string, error) {
+	args := append(c.baseArgs(),
+		"-X", "-q", "-A", "-t", "-v", "ON_ERROR_STOP=1",
+		"-F", fieldSep, "-R", recordSep, "-f", "-",
+	)
+	cmd := Command(ctx, "psql", args...)
+	cmd.Env = c.env()
+	cmd.Stdin = strings.NewReader(sql)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if stderr.Len() > 0 {
+			return nil, fmt.Errorf("psql query failed: %v\n%s", err, stderr.String())
+		}
+		return nil, err
+	}
+	out := strings.TrimSuffix(stdout.String(), "\n")
+	if out == "" {
+		return nil, nil
+	}
+	var rows [][]string
+	for _, rec := range strings.Split(strings.ReplaceAll(out, "\n", recordSep), recordSep) {
+		rows = append(rows, strings.Split(rec, fieldSep))
+	}
+	return rows, nil
+}
+
+// QuoteLiteral quotes s as a SQL string literal.
+func QuoteLiteral(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" }
+
+// QuoteIdent quotes s as a SQL identifier.
+func QuoteIdent(s string) string { return `"` + strings.ReplaceAll(s, `"`, `""`) + `"` }
+
+// TableInfo describes a table (or materialized view) in a database catalog.
+type TableInfo struct {
+	Schema string
+	Name   string
+	Rows   int64 // planner estimate; -1 if the table was never analyzed
+	Bytes  int64 // total size including indexes and TOAST
+}
+
+// QualifiedName returns schema.name.
+func (t TableInfo) QualifiedName() string { return t.Schema + "." + t.Name }
+
+// Tables lists the tables, partitioned tables and materialized views in the given schemas.
+func Tables(ctx context.Context, c Conn, schemas []string) ([]TableInfo, error) {
+	rows, err := Query(ctx, c, fmt.Sprintf(`SELECT n.nspname, c.relname, c.reltuples::bigint, pg_total_relation_size(c.oid)
+FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind IN ('r', 'p', 'm') AND NOT c.relispartition AND n.nspname IN (%s)
+ORDER BY 1, 2`, quoteList(schemas)))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]TableInfo, 0, len(rows))
+	for _, r := range rows {
+		if len(r) != 4 {
+			return nil, fmt.Errorf("unexpected catalog row %q", r)
+		}
+		t := TableInfo{Schema: r[0], Name: r[1]}
+		t.Rows, _ = strconv.ParseInt(r[2], 10, 64)
+		t.Bytes, _ = strconv.ParseInt(r[3], 10, 64)
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// dumpTableRE captures the possibly quoted, schema-qualified name of a created relation.
+var dumpTableRE = regexp.MustCompile(`^CREATE (?:UNLOGGED )?(?:TABLE|MATERIALIZED VIEW) (?:IF NOT EXISTS )?((?:"(?:[^"]|"")*"|[^\s(".]+)(?:\.(?:"(?:[^"]|"")*"|[^\s(".]+))*)`)
+
+// DumpTables lists the schema-qualified tables and materialized views a plain SQL dump
+// creates, read from its CREATE statements. Identifiers are returned unquoted.
+func DumpTables(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReaderSize(f, 64*1024)
+	var out []string
+	for {
+		// Only the start of each line matters; COPY data lines can be huge, so skip
+		// the rest of long lines instead of buffering them.
+		line, isPrefix, err := r.ReadLine()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if m := dumpTableRE.FindSubmatch(line); m != nil {
+			out = append(out, unquoteQualified(string(m[1])))
+		}
+		for isPrefix {
+			if _, isPrefix, err = r.ReadLine(); err != nil {
+				return out, nil
+			}
+		}
+	}
+}
+
+// unquoteQualified turns `"My Schema"."Table"` into My Schema.Table; unquoted names
+// such as public.users are returned as they are.
+func unquoteQualified(name string) string {
+	var sb strings.Builder
+	inQuotes := false
+	for i := 0; i < len(name); i++ {
+		ch := name[i]
+		switch {
+		case ch == '"' && inQuotes && i+1 < len(name) && name[i+1] == '"':
+			sb.WriteByte('"')
+			i++
+		case ch == '"':
+			inQuotes = !inQuotes
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,41 @@
+package copy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// DatabaseInfo describes one database on a server.
+type DatabaseInfo struct {
+	Name  string
+	Bytes int64
+}
+
+// Databases lists the databases on the server of c that accept connections, without
+// the templates, sorted by name.
+func Databases(ctx context.Context, c Conn) ([]DatabaseInfo, error) {
+	rows, err := Query(ctx, c, `SELECT datname, pg_database_size(oid)
+FROM pg_database
+WHERE datallowconn AND NOT datistemplate
+ORDER BY 1;`)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]DatabaseInfo, 0, len(rows))
+	for _, r := range rows {
+		if len(r) != 2 {
+			return nil, fmt.Errorf("unexpected catalog row %q", r)
+		}
+		d := DatabaseInfo{Name: r[0]}
+		d.Bytes, _ = strconv.ParseInt(r[1], 10, 64)
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// CreateDatabase creates an empty database called name on the server of c.
+func CreateDatabase(ctx context.Context, c Conn, name string) error {
+	_, err := Query(ctx, c, fmt.Sprintf("CREATE DATABASE %s;", QuoteIdent(name)))
+	return err
+}
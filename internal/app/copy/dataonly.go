@@ -0,0 +1,169 @@
+package copy
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Column describes a table column in a database catalog.
+type Column struct {
+	Name       string
+	Type       string // formatted type, e.g. "character varying(255)"
+	NotNull    bool
+	HasDefault bool // a default or an identity/generated value
+}
+
+// Columns returns the columns of every table in the given schemas, keyed by
+// schema-qualified table name, in column order.
+func Columns(ctx context.Context, c Conn, schemas []string) (map[string][]Column, error) {
+	rows, err := Query(ctx, c, fmt.Sprintf(`SELECT n.nspname || '.' || c.relname, a.attname,
+  format_type(a.atttypid, a.atttypmod), a.attnotnull,
+  a.atthasdef OR a.attidentity <> '' OR a.attgenerated <> ''
+FROM pg_attribute a
+JOIN pg_class c ON c.oid = a.attrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind IN ('r', 'p') AND NOT c.relispartition AND a.attnum > 0 AND NOT a.attisdropped
+  AND n.nspname IN (%s)
+ORDER BY 1, a.attnum;`, quoteList(schemas)))
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string][]Column)
+	for _, r := range rows {
+		if len(r) != 5 {
+			return nil, fmt.Errorf("unexpected catalog row %q", r)
+		}
+		out[r[0]] = append(out[r[0]], Column{Name: r[1], Type: r[2], NotNull: r[3] == "t", HasDefault: r[4] == "t"})
+	}
+	return out, nil
+}
+
+// CompareColumns lists every problem that would break loading the source tables'
+// data into the destination's existing tables. Destination-only tables are ignored;
+// destination-only columns are fine as long as they are nullable or have a default.
+func CompareColumns(src, dst map[string][]Column) []string {
+	var problems []string
+	for _, table := range sortedKeys(src) {
+		dcols, ok := dst[table]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: table missing in destination", table))
+			continue
+		}
+		for _, sc := range src[table] {
+			i := slices.IndexFunc(dcols, func(dc Column) bool { return dc.Name == sc.Name })
+			if i < 0 {
+				problems = append(problems, fmt.Sprintf("%s.%s: column missing in destination", table, sc.Name))
+				continue
+			}
+			if dc := dcols[i]; dc.Type != sc.Type {
+				problems = append(problems, fmt.Sprintf("%s.%s: type differs (source %s, destination %s)", table, sc.Name, sc.Type, dc.Type))
+			}
+		}
+		for _, dc := range dcols {
+			inSource := slices.ContainsFunc(src[table], func(sc Column) bool { return sc.Name == dc.Name })
+			if !inSource && dc.NotNull && !dc.HasDefault {
+				problems = append(problems, fmt.Sprintf("%s.%s: NOT NULL without default in destination, but missing in source", table, dc.Name))
+			}
+		}
+	}
+	return problems
+}
+
+// ForeignKey is a foreign key from Child to Parent (both schema-qualified).
+type ForeignKey struct {
+	Name, Child, Parent string
+}
+
+// ForeignKeys lists the foreign keys whose referencing table is in the given schemas.
+func ForeignKeys(ctx context.Context, c Conn, schemas []string) ([]ForeignKey, error) {
+	rows, err := Query(ctx, c, fmt.Sprintf(`SELECT con.conname, cn.nspname || '.' || cc.relname, pn.nspname || '.' || pc.relname
+FROM pg_constraint con
+JOIN pg_class cc ON cc.oid = con.conrelid JOIN pg_namespace cn ON cn.oid = cc.relnamespace
+JOIN pg_class pc ON pc.oid = con.confrelid JOIN pg_namespace pn ON pn.oid = pc.relnamespace
+WHERE con.contype = 'f' AND cn.nspname IN (%s)
+ORDER BY 1, 2;`, quoteList(schemas)))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ForeignKey, 0, len(rows))
+	for _, r := range rows {
+		if len(r) != 3 {
+			return nil, fmt.Errorf("unexpected catalog row %q", r)
+		}
+		out = append(out, ForeignKey{Name: r[0], Child: r[1], Parent: r[2]})
+	}
+	return out, nil
+}
+
+// TruncateOrder sorts tables so that referencing tables come before the tables they
+// reference. Tables in a reference cycle keep their relative order.
+func TruncateOrder(tables []string, fks []ForeignKey) []string {
+	children := make(map[string][]string)
+	for _, fk := range fks {
+		if fk.Child != fk.Parent && slices.Contains(tables, fk.Child) && slices.Contains(tables, fk.Parent) {
+			children[fk.Parent] = append(children[fk.Parent], fk.Child)
+		}
+	}
+	var out []string
+	state := make(map[string]int) // 1 = visiting, 2 = done
+	var visit func(t string)
+	visit = func(t string) {
+		if state[t] != 0 {
+			return
+		}
+		state[t] = 1
+		for _, ch := range children[t] {
+			visit(ch)
+		}
+		state[t] = 2
+		out = append(out, t)
+	}
+	for _, t := range tables {
+		visit(t)
+	}
+	return out
+}
+
+// TruncateBlockers returns the foreign keys from tables outside the list into tables
+// in it; TRUNCATE refuses to empty a table such keys point at.
+func TruncateBlockers(tables []string, fks []ForeignKey) []ForeignKey {
+	var out []ForeignKey
+	for _, fk := range fks {
+		if !slices.Contains(tables, fk.Child) && slices.Contains(tables, fk.Parent) {
+			out = append(out, fk)
+		}
+	}
+	return out
+}
+
+// Truncate empties tables in the given order with a single TRUNCATE statement.
+func Truncate(ctx context.Context, c Conn, tables []string) error {
+	if len(tables) == 0 {
+		return nil
+	}
+	quoted := make([]string, len(tables))
+	for i, t := range tables {
+		quoted[i] = QualifiedIdent(t)
+	}
+	_, err := Query(ctx, c, "TRUNCATE "+strings.Join(quoted, ", ")+";")
+	return err
+}
+
+func quoteList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = QuoteLiteral(v)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,125 @@
+package copy
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestTruncateOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		tables []string
+		fks    []ForeignKey
+		want   []string
+	}{
+		{
+			name:   "no foreign keys",
+			tables: []string{"public.a", "public.b"},
+			want:   []string{"public.a", "public.b"},
+		},
+		{
+			name:   "children first",
+			tables: []string{"public.users", "public.orders", "public.items"},
+			fks: []ForeignKey{
+				{Name: "orders_user_fk", Child: "public.orders", Parent: "public.users"},
+				{Name: "items_order_fk", Child: "public.items", Parent: "public.orders"},
+			},
+			want: []string{"public.items", "public.orders", "public.users"},
+		},
+		{
+			name:   "self reference",
+			tables: []string{"public.categories"},
+			fks:    []ForeignKey{{Name: "parent_fk", Child: "public.categories", Parent: "public.categories"}},
+			want:   []string{"public.categories"},
+		},
+		{
+			name:   "keys to other tables are ignored",
+			tables: []string{"public.users", "public.orders"},
+			fks: []ForeignKey{
+				{Name: "audit_fk", Child: "public.audit", Parent: "public.users"},
+				{Name: "orders_user_fk", Child: "public.orders", Parent: "public.users"},
+			},
+			want: []string{"public.orders", "public.users"},
+		},
+		{
+			name:   "cycle",
+			tables: []string{"public.a", "public.b", "public.c"},
+			fks: []ForeignKey{
+				{Name: "a_b", Child: "public.a", Parent: "public.b"},
+				{Name: "b_a", Child: "public.b", Parent: "public.a"},
+				{Name: "c_a", Child: "public.c", Parent: "public.a"},
+			},
+			want: []string{"public.b", "public.c", "public.a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TruncateOrder(tt.tables, tt.fks)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TruncateOrder() = %v, want %v", got, tt.want)
+			}
+			if !slices.Equal(slices.Sorted(slices.Values(got)), slices.Sorted(slices.Values(tt.tables))) {
+				t.Errorf("TruncateOrder() = %v, want each of %v once", got, tt.tables)
+			}
+		})
+	}
+}
+
+func TestTruncateBlockers(t *testing.T) {
+	fks := []ForeignKey{
+		{Name: "orders_user_fk", Child: "public.orders", Parent: "public.users"},
+		{Name: "audit_fk", Child: "public.audit", Parent: "public.users"},
+	}
+	got := TruncateBlockers([]string{"public.users", "public.orders"}, fks)
+	if want := fks[1:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("TruncateBlockers() = %v, want %v", got, want)
+	}
+}
+
+func TestCompareColumns(t *testing.T) {
+	id := Column{Name: "id", Type: "bigint", NotNull: true, HasDefault: true}
+	name := Column{Name: "name", Type: "text"}
+	tests := []struct {
+		name     string
+		src, dst map[string][]Column
+		want     []string
+	}{
+		{
+			name: "same",
+			src:  map[string][]Column{"public.users": {id, name}},
+			dst:  map[string][]Column{"public.users": {id, name}},
+		},
+		{
+			name: "destination-only table and nullable column",
+			src:  map[string][]Column{"public.users": {id}},
+			dst: map[string][]Column{
+				"public.users":  {id, name, {Name: "created_at", Type: "timestamp", NotNull: true, HasDefault: true}},
+				"public.extras": {id},
+			},
+		},
+		{
+			name: "every problem",
+			src: map[string][]Column{
+				"public.users":  {id, name, {Name: "age", Type: "integer"}},
+				"public.orders": {id},
+			},
+			dst: map[string][]Column{
+				"public.users": {{Name: "id", Type: "integer", NotNull: true}, name, {Name: "email", Type: "text", NotNull: true}},
+			},
+			want: []string{
+				"public.orders: table missing in destination",
+				"public.users.id: type differs (source bigint, destination integer)",
+				"public.users.age: column missing in destination",
+				"public.users.email: NOT NULL without default in destination, but missing in source",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CompareColumns(tt.src, tt.dst); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CompareColumns() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package copy
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Fingerprint identifies the database of c and the shape of its schema: the database's
+// OID (which changes when it is dropped and recreated), the server version and a hash
+// of every user table, view and column with its type. Rows don't count, so it stays
+// the same while data changes.
+func Fingerprint(ctx context.Context, c Conn) (string, error) {
+	rows, err := Query(ctx, c, `SELECT
+  (SELECT oid FROM pg_database WHERE datname = current_database()),
+  current_setting('server_version_num'),
+  md5(coalesce((
+    SELECT string_agg(format('%s.%s.%s:%s', n.nspname, r.relname, a.attname, format_type(a.atttypid, a.atttypmod)), ','
+      ORDER BY n.nspname, r.relname, a.attnum)
+    FROM pg_class r
+    JOIN pg_namespace n ON n.oid = r.relnamespace
+    JOIN pg_attribute a ON a.attrelid = r.oid AND a.attnum > 0 AND NOT a.attisdropped
+    WHERE r.relkind IN ('r', 'p', 'v', 'm', 'f')
+      AND n.nspname NOT LIKE 'pg\_%' AND n.nspname <> 'information_schema'
+  ), ''));`)
+	if err != nil {
+		return "", err
+	}
+	if len(rows) != 1 || len(rows[0]) != 3 {
+		return "", fmt.Errorf("unexpected fingerprint output %q", rows)
+	}
+	return strings.Join(rows[0], ":"), nil
+}
+
+// CommandLine renders program with args as it would be typed in a shell, prefixed with
+// the environment it gets from c and env. The password is redacted, so the result is
+// safe to show or store.
+func CommandLine(c Conn, program string, args []string, env ...string) string {
+	parts := []string{"PGPASSWORD=********"}
+	if c.SSLMode != "" {
+		parts = append(parts, "PGSSLMODE="+c.SSLMode)
+	}
+	for _, e := range env {
+		name, value, _ := strings.Cut(e, "=")
+		parts = append(parts, name+"="+shellQuote(value))
+	}
+	parts = append(parts, program)
+	for _, a := range args {
+		parts = append(parts, shellQuote(a))
+	}
+	return strings.Join(parts, " ")
+}
+
+// ImportCommandLine is CommandLine for the psql run ImportWithOptions starts.
+func ImportCommandLine(dst Conn, file string, opts ImportOptions) string {
+	return CommandLine(dst, "psql", ImportArgs(dst, opts), opts.env()...) + " < " + shellQuote(file)
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,64 @@
+package copy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCommandLine(t *testing.T) {
+	c := Conn{Host: "db.internal", Port: 5432, User: "app", Password: "s3cr'et pass", DBName: "shop", SSLMode: "require"}
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{
+			name: "dump",
+			got:  CommandLine(c, "pg_dump", DumpArgs(c, "/tmp/dump.sql", DumpOptions{Schemas: []string{"public"}})),
+			want: "PGPASSWORD=******** PGSSLMODE=require pg_dump -h db.internal -p 5432 -U app -d shop",
+		},
+		{
+			name: "wipe",
+			got:  CommandLine(c, "psql", WipeArgs(c, "public")),
+			want: `psql -h db.internal -p 5432 -U app -d shop -c 'DROP SCHEMA IF EXISTS "public" CASCADE; CREATE SCHEMA "public";'`,
+		},
+		{
+			name: "import",
+			got:  ImportCommandLine(c, "/tmp/my dump.sql", ImportOptions{ReplicaRole: true, StopOnError: true}),
+			want: "PGOPTIONS='-c session_replication_role=replica' psql -h db.internal -p 5432 -U app -d shop -v ON_ERROR_STOP=1 < '/tmp/my dump.sql'",
+		},
+		{
+			name: "globals",
+			got:  CommandLine(c, "pg_dumpall", GlobalsArgs(c, false)),
+			want: "pg_dumpall -h db.internal -p 5432 -U app -l shop --globals-only --no-role-passwords",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(tt.got, tt.want) {
+				t.Errorf("command line\n  %s\nwant it to contain\n  %s", tt.got, tt.want)
+			}
+			if !strings.HasPrefix(tt.got, "PGPASSWORD=******** ") {
+				t.Errorf("command line %q doesn't start with the redacted password", tt.got)
+			}
+			if strings.Contains(tt.got, "s3cr") {
+				t.Errorf("command line %q leaks the password", tt.got)
+			}
+		})
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"plain":       "plain",
+		"a b":         "'a b'",
+		"it's":        `'it'\''s'`,
+		"$(rm -rf /)": "'$(rm -rf /)'",
+		"":            "''",
+	}
+	for in, want := range tests {
+		if got := shellQuote(in); got != want {
+			t.Errorf("shellQuote(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package copy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DumpGlobals runs pg_dumpall --globals-only against the server of c and returns the
+// SQL. Without passwords, role passwords are left out of the dump.
+func DumpGlobals(ctx context.Context, c Conn, passwords bool) (string, error) {
+	cmd := Command(ctx, "pg_dumpall", GlobalsArgs(c, passwords)...)
+	cmd.Env = c.env()
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if stderr.Len() > 0 {
+			return "", fmt.Errorf("pg_dumpall failed: %v\n%s", err, stderr.String())
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// GlobalsArgs returns the pg_dumpall arguments DumpGlobals runs with.
+func GlobalsArgs(c Conn, passwords bool) []string {
+	args := []string{
+		"-h", c.Host,
+		"-p", fmt.Sprintf("%d", c.Port),
+		"-U", c.User,
+		"-l", c.DBName,
+		"--globals-only",
+	}
+	if !passwords {
+		args = append(args, "--no-role-passwords")
+	}
+	return args
+}
+
+// GlobalsFilter selects which globals of a pg_dumpall dump are copied.
+type GlobalsFilter struct {
+	Roles       []string // glob patterns of role names to copy; empty means every role
+	Superusers  bool     // also copy superuser roles
+	Replication bool     // also copy replication roles
+	Tablespaces bool     // also create tablespaces
+	Skip        []string // roles never to touch, such as the destination's own user
+}
+
+// GlobalRole is one role of a globals dump and what happens to it.
+type GlobalRole struct {
+	Name       string
+	Attributes []string // e.g. LOGIN, CREATEDB, PASSWORD
+	Member     []string // roles it is granted
+	Skipped    string   // why it is not copied, or "" if it is
+	Exists     bool     // already on the destination, set by CheckGlobals
+}
+
+// GlobalsPlan is the filtered content of a globals dump.
+type GlobalsPlan struct {
+	Roles       []GlobalRole
+	Tablespaces []string
+	statements  []string
+}
+
+// Empty reports whether the plan changes nothing.
+func (p GlobalsPlan) Empty() bool { return len(p.statements) == 0 }
+
+var (
+	identPattern   = `("(?:[^"]|"")*"|[^\s;"]+)`
+	createRoleRE   = regexp.MustCompile(`^CREATE ROLE ` + identPattern + `;$`)
+	roleStmtRE     = regexp.MustCompile(`^(?:ALTER|COMMENT ON) ROLE ` + identPattern + `[\s;]`)
+	roleGrantRE    = regexp.MustCompile(`^GRANT ` + identPattern + ` TO ` + identPattern + `[\s;]`)
+	grantedByRE    = regexp.MustCompile(` GRANTED BY ` + identPattern + `;$`)
+	tablespaceRE   = regexp.MustCompile(`^CREATE TABLESPACE ` + identPattern + `\s`)
+	tablespaceStmt = regexp.MustCompile(`^(?:ALTER|COMMENT ON) TABLESPACE |^(?:GRANT|REVOKE) .* ON TABLESPACE `)
+	// the grantee of a tablespace privilege, or its new owner
+	tablespaceGranteeRE = regexp.MustCompile(` (?:TO|FROM) ` + identPattern + `;$`)
+)
+
+// PlanGlobals filters a pg_dumpall --globals-only dump. Roles that are superusers or
+// replication roles (unless allowed), don't match f.Roles or are in f.Skip are left
+// out together with their memberships and settings. Per-database role settings are
+// left out because those databases may not exist on the destination.
+func PlanGlobals(dump string, f GlobalsFilter) GlobalsPlan {
+	var plan GlobalsPlan
+	stmts := dumpStatements(dump)
+	roles := make(map[string]*GlobalRole)
+	var order []string
+	role := func(name string) *GlobalRole {
+		if r, ok := roles[name]; ok {
+			return r
+		}
+		roles[name] = &GlobalRole{Name: name}
+		order = append(order, name)
+		return roles[name]
+	}
+
+	// First pass: learn every role and its attributes.
+	for _, s := range stmts {
+		if m := createRoleRE.FindStringSubmatch(s); m != nil {
+			role(unquoteIdent(m[1]))
+		} else if m := roleStmtRE.FindStringSubmatch(s); m != nil && strings.HasPrefix(s, "ALTER ROLE ") && strings.Contains(s, " WITH ") {
+			r := role(unquoteIdent(m[1]))
+			_, attrs, _ := strings.Cut(strings.TrimSuffix(s, ";"), " WITH ")
+			r.Attributes = nil
+			fields := sqlFields(attrs)
+			for i := 0; i < len(fields); i++ {
+				switch a := fields[i]; {
+				case a == "SUPERUSER":
+					r.Attributes = append(r.Attributes, a)
+					r.Skipped = "superuser"
+				case a == "REPLICATION":
+					r.Attributes = append(r.Attributes, a)
+					if r.Skipped == "" {
+						r.Skipped = "replication"
+					}
+				case a == "PASSWORD" || a == "CONNECTION" || a == "VALID":
+					// PASSWORD 'x', CONNECTION LIMIT n and VALID UNTIL 'x' take arguments;
+					// only the keyword is shown.
+					r.Attributes = append(r.Attributes, a)
+					i++
+					if a != "PASSWORD" {
+						i++
+					}
+				case strings.HasPrefix(a, "NO") || a == "INHERIT":
+				default:
+					r.Attributes = append(r.Attributes, a)
+				}
+			}
+		}
+	}
+	for _, name := range order {
+		r := roles[name]
+		switch {
+		case r.Skipped == "superuser" && f.Superusers, r.Skipped == "replication" && f.Replication:
+			r.Skipped = ""
+		}
+		switch {
+		case r.Skipped != "":
+		case slices.Contains(f.Skip, name):
+			r.Skipped = "destination user"
+		case len(f.Roles) > 0 && !slices.ContainsFunc(f.Roles, func(p string) bool { ok, _ := path.Match(p, name); return ok }):
+			r.Skipped = "not in roles"
+		}
+	}
+	copied := func(name string) bool { r, ok := roles[name]; return ok && r.Skipped == "" }
+
+	// Second pass: keep the statements of copied roles, made idempotent.
+	for _, s := range stmts {
+		switch {
+		case createRoleRE.MatchString(s):
+			name := unquoteIdent(createRoleRE.FindStringSubmatch(s)[1])
+			if copied(name) {
+				plan.statements = append(plan.statements, fmt.Sprintf("SELECT %s WHERE NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = %s)\n\\gexec",
+					QuoteLiteral(strings.TrimSuffix(s, ";")), QuoteLiteral(name)))
+			}
+		case roleStmtRE.MatchString(s):
+			name := unquoteIdent(roleStmtRE.FindStringSubmatch(s)[1])
+			if copied(name) && !strings.Contains(s, " IN DATABASE ") {
+				plan.statements = append(plan.statements, s)
+			}
+		case roleGrantRE.MatchString(s) && !strings.Contains(s, " ON "):
+			m := roleGrantRE.FindStringSubmatch(s)
+			granted, member := unquoteIdent(m[1]), unquoteIdent(m[2])
+			if copied(member) && (copied(granted) || roles[granted] == nil) {
+				roles[member].Member = append(roles[member].Member, granted)
+				// The grantor may not exist on the destination; grant as the connecting user.
+				plan.statements = append(plan.statements, grantedByRE.ReplaceAllString(s, ";"))
+			}
+		case tablespaceRE.MatchString(s):
+			if f.Tablespaces {
+				name := unquoteIdent(tablespaceRE.FindStringSubmatch(s)[1])
+				plan.Tablespaces = append(plan.Tablespaces, name)
+				plan.statements = append(plan.statements, fmt.Sprintf("SELECT %s WHERE NOT EXISTS (SELECT 1 FROM pg_tablespace WHERE spcname = %s)\n\\gexec",
+					QuoteLiteral(strings.TrimSuffix(s, ";")), QuoteLiteral(name)))
+			}
+		case tablespaceStmt.MatchString(s):
+			if m := tablespaceGranteeRE.FindStringSubmatch(s); m != nil && roles[unquoteIdent(m[1])] != nil && !copied(unquoteIdent(m[1])) {
+				continue
+			}
+			if f.Tablespaces {
+				plan.statements = append(plan.statements, s)
+			}
+		}
+	}
+	for _, name := range order {
+		plan.Roles = append(plan.Roles, *roles[name])
+	}
+	return plan
+}
+
+// CheckGlobals marks the roles of plan that already exist on c. Those are altered
+// to match the source instead of created.
+func CheckGlobals(ctx context.Context, c Conn, plan *GlobalsPlan) error {
+	var names []string
+	for _, r := range plan.Roles {
+		if r.Skipped == "" {
+			names = append(names, r.Name)
+		}
+	}
+	missing, err := MissingRoles(ctx, c, names)
+	if err != nil {
+		return err
+	}
+	for i, r := range plan.Roles {
+		plan.Roles[i].Exists = r.Skipped == "" && !slices.Contains(missing, r.Name)
+	}
+	return nil
+}
+
+// ApplyGlobals runs the statements of plan on c, stopping at the first error.
+func ApplyGlobals(ctx context.Context, c Conn, plan GlobalsPlan) error {
+	if plan.Empty() {
+		return nil
+	}
+	_, err := Query(ctx, c, strings.Join(plan.statements, "\n")+"\n")
+	return err
+}
+
+// dumpStatements splits SQL into statements, dropping comments, blank lines and SET
+// commands. A statement ends at a line that ends with a semicolon.
+func dumpStatements(sql string) []string {
+	var out []string
+	var cur strings.Builder
+	sc := bufio.NewScanner(strings.NewReader(sql))
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if cur.Len() == 0 && (strings.TrimSpace(line) == "" || strings.HasPrefix(line, "--") || strings.HasPrefix(line, "SET ") || strings.HasPrefix(line, `\`)) {
+			continue
+		}
+		if cur.Len() > 0 {
+			cur.WriteByte('\n')
+		}
+		cur.WriteString(line)
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			out = append(out, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		}
+	}
+	return out
+}
+
+// sqlFields splits s around runs of white space like strings.Fields, but keeps
+// single-quoted literals such as '2027-01-01 00:00:00+00' in one field.
+func sqlFields(s string) []string {
+	var out []string
+	var cur strings.Builder
+	quoted := false
+	for _, r := range s {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+			cur.WriteRune(r)
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if cur.Len() > 0 {
+				out = append(out, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		out = append(out, cur.String())
+	}
+	return out
+}
+
+func unquoteIdent(s string) string {
+	if strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) && len(s) >= 2 {
+		return strings.ReplaceAll(s[1:len(s)-1], `""`, `"`)
+	}
+	return s
+}
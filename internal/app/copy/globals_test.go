@@ -0,0 +1,140 @@
+package copy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const globalsDump = `--
+-- PostgreSQL database cluster dump
+--
+
+SET default_transaction_read_only = off;
+
+SET client_encoding = 'UTF8';
+SET standard_conforming_strings = on;
+
+--
+-- Roles
+--
+
+CREATE ROLE app;
+ALTER ROLE app WITH NOSUPERUSER INHERIT NOCREATEROLE NOCREATEDB LOGIN NOREPLICATION NOBYPASSRLS PASSWORD 'SCRAM-SHA-256$4096:abc';
+CREATE ROLE postgres;
+ALTER ROLE postgres WITH SUPERUSER INHERIT CREATEROLE CREATEDB LOGIN REPLICATION BYPASSRLS;
+CREATE ROLE readers;
+ALTER ROLE readers WITH NOSUPERUSER INHERIT NOCREATEROLE NOCREATEDB NOLOGIN NOREPLICATION NOBYPASSRLS;
+CREATE ROLE replicator;
+ALTER ROLE replicator WITH NOSUPERUSER INHERIT NOCREATEROLE NOCREATEDB LOGIN REPLICATION NOBYPASSRLS CONNECTION LIMIT 5;
+CREATE ROLE "Report User";
+ALTER ROLE "Report User" WITH NOSUPERUSER INHERIT NOCREATEROLE NOCREATEDB LOGIN NOREPLICATION NOBYPASSRLS VALID UNTIL '2027-01-01 00:00:00+00';
+ALTER ROLE app SET statement_timeout TO '30s';
+ALTER ROLE app IN DATABASE shop SET search_path TO 'shop, public';
+COMMENT ON ROLE readers IS 'read-only access';
+
+--
+-- Role memberships
+--
+
+GRANT readers TO app GRANTED BY postgres;
+GRANT readers TO "Report User" GRANTED BY postgres;
+GRANT pg_read_all_data TO replicator GRANTED BY postgres;
+
+--
+-- Tablespaces
+--
+
+CREATE TABLESPACE fast OWNER postgres LOCATION '/mnt/fast';
+GRANT CREATE ON TABLESPACE fast TO app;
+GRANT CREATE ON TABLESPACE fast TO replicator;
+
+--
+-- PostgreSQL database cluster dump complete
+--
+`
+
+func TestPlanGlobals(t *testing.T) {
+	plan := PlanGlobals(globalsDump, GlobalsFilter{Skip: []string{"app_dev"}})
+
+	var skipped []string
+	for _, r := range plan.Roles {
+		skipped = append(skipped, r.Name+": "+r.Skipped)
+	}
+	want := []string{"app: ", "postgres: superuser", "readers: ", "replicator: replication", "Report User: "}
+	if !reflect.DeepEqual(skipped, want) {
+		t.Errorf("roles = %q, want %q", skipped, want)
+	}
+	if got, want := plan.Roles[0].Attributes, []string{"LOGIN", "PASSWORD"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("app attributes = %q, want %q", got, want)
+	}
+	if got, want := plan.Roles[3].Attributes, []string{"LOGIN", "REPLICATION", "CONNECTION"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("replicator attributes = %q, want %q", got, want)
+	}
+	if got, want := plan.Roles[4].Attributes, []string{"LOGIN", "VALID"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Report User attributes = %q, want %q", got, want)
+	}
+	if got, want := plan.Roles[0].Member, []string{"readers"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("app is member of %q, want %q", got, want)
+	}
+	if len(plan.Tablespaces) != 0 {
+		t.Errorf("tablespaces = %q, want none", plan.Tablespaces)
+	}
+
+	wantStmts := []string{
+		`SELECT 'CREATE ROLE app' WHERE NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'app')` + "\n\\gexec",
+		"ALTER ROLE app WITH NOSUPERUSER INHERIT NOCREATEROLE NOCREATEDB LOGIN NOREPLICATION NOBYPASSRLS PASSWORD 'SCRAM-SHA-256$4096:abc';",
+		`SELECT 'CREATE ROLE readers' WHERE NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'readers')` + "\n\\gexec",
+		"ALTER ROLE readers WITH NOSUPERUSER INHERIT NOCREATEROLE NOCREATEDB NOLOGIN NOREPLICATION NOBYPASSRLS;",
+		`SELECT 'CREATE ROLE "Report User"' WHERE NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'Report User')` + "\n\\gexec",
+		"ALTER ROLE \"Report User\" WITH NOSUPERUSER INHERIT NOCREATEROLE NOCREATEDB LOGIN NOREPLICATION NOBYPASSRLS VALID UNTIL '2027-01-01 00:00:00+00';",
+		"ALTER ROLE app SET statement_timeout TO '30s';",
+		"COMMENT ON ROLE readers IS 'read-only access';",
+		"GRANT readers TO app;",
+		`GRANT readers TO "Report User";`,
+	}
+	if !reflect.DeepEqual(plan.statements, wantStmts) {
+		t.Errorf("statements =\n%s\nwant\n%s", strings.Join(plan.statements, "\n"), strings.Join(wantStmts, "\n"))
+	}
+}
+
+func TestPlanGlobalsFilters(t *testing.T) {
+	plan := PlanGlobals(globalsDump, GlobalsFilter{
+		Roles:       []string{"app", "rep*", "readers"},
+		Replication: true,
+		Tablespaces: true,
+		Skip:        []string{"readers"},
+	})
+	var skipped []string
+	for _, r := range plan.Roles {
+		skipped = append(skipped, r.Name+": "+r.Skipped)
+	}
+	want := []string{"app: ", "postgres: superuser", "readers: destination user", "replicator: ", "Report User: not in roles"}
+	if !reflect.DeepEqual(skipped, want) {
+		t.Errorf("roles = %q, want %q", skipped, want)
+	}
+	if !reflect.DeepEqual(plan.Tablespaces, []string{"fast"}) {
+		t.Errorf("tablespaces = %q, want [fast]", plan.Tablespaces)
+	}
+	joined := strings.Join(plan.statements, "\n")
+	for _, s := range []string{
+		"GRANT pg_read_all_data TO replicator;",
+		"GRANT CREATE ON TABLESPACE fast TO app;",
+		"GRANT CREATE ON TABLESPACE fast TO replicator;",
+	} {
+		if !strings.Contains(joined, s) {
+			t.Errorf("statements lack %q:\n%s", s, joined)
+		}
+	}
+	for _, s := range []string{"GRANT readers TO", "CREATE ROLE postgres", "Report User"} {
+		if strings.Contains(joined, s) {
+			t.Errorf("statements contain %q:\n%s", s, joined)
+		}
+	}
+}
+
+func TestPlanGlobalsEmpty(t *testing.T) {
+	if plan := PlanGlobals(globalsDump, GlobalsFilter{Roles: []string{"nobody"}}); !plan.Empty() {
+		t.Errorf("plan = %q, want it empty", plan.statements)
+	}
+}
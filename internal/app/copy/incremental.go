@@ -0,0 +1,84 @@
+package copy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// watermarkTable holds the watermarks of incremental syncs kept on the destination.
+// It lives outside WipeSchema so a full refresh doesn't drop it.
+const watermarkTable = "psql_transporter.watermarks"
+
+// MaxValue returns the largest value of column in table as text, or "" when the
+// table has no non-NULL values.
+func MaxValue(ctx context.Context, c Conn, table, column string) (string, error) {
+	rows, err := Query(ctx, c, fmt.Sprintf("SELECT max(%s)::text FROM %s;", QuoteIdent(column), QualifiedIdent(table)))
+	if err != nil {
+		return "", err
+	}
+	if len(rows) != 1 {
+		return "", fmt.Errorf("unexpected output %q", rows)
+	}
+	return rows[0][0], nil
+}
+
+// ChangedSince is the condition selecting the rows of a table whose column is
+// between from and to, inclusive. An empty from has no lower bound. Rows at from
+// are copied again so that rows sharing the last watermark are not missed.
+func ChangedSince(column, from, to string) string {
+	cond := fmt.Sprintf("%s <= %s", QuoteIdent(column), QuoteLiteral(to))
+	if from != "" {
+		cond = fmt.Sprintf("%s >= %s AND %s", QuoteIdent(column), QuoteLiteral(from), cond)
+	}
+	return cond
+}
+
+// LoadWatermarks returns the watermarks stored on c for syncs from source, keyed by table.
+func LoadWatermarks(ctx context.Context, c Conn, source string) (map[string]string, error) {
+	exists, err := TableExists(ctx, c, watermarkTable)
+	if err != nil || !exists {
+		return nil, err
+	}
+	rows, err := Query(ctx, c, fmt.Sprintf("SELECT table_name, watermark FROM %s WHERE source = %s;",
+		QualifiedIdent(watermarkTable), QuoteLiteral(source)))
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(rows))
+	for _, r := range rows {
+		if len(r) != 2 {
+			return nil, fmt.Errorf("unexpected watermark row %q", r)
+		}
+		out[r[0]] = r[1]
+	}
+	return out, nil
+}
+
+// StoreWatermarks records the watermarks of a sync from source on c, creating the
+// metadata table on first use.
+func StoreWatermarks(ctx context.Context, c Conn, source string, marks map[string]string) error {
+	if len(marks) == 0 {
+		return nil
+	}
+	schema, _, _ := strings.Cut(watermarkTable, ".")
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "CREATE SCHEMA IF NOT EXISTS %s;\n", QuoteIdent(schema))
+	fmt.Fprintf(&sb, `CREATE TABLE IF NOT EXISTS %s (
+  source text NOT NULL,
+  table_name text NOT NULL,
+  watermark text NOT NULL,
+  synced_at timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY (source, table_name)
+);
+`, QualifiedIdent(watermarkTable))
+	var values []string
+	for _, table := range sortedKeys(marks) {
+		values = append(values, fmt.Sprintf("(%s, %s, %s)", QuoteLiteral(source), QuoteLiteral(table), QuoteLiteral(marks[table])))
+	}
+	fmt.Fprintf(&sb, `INSERT INTO %s (source, table_name, watermark) VALUES %s
+ON CONFLICT (source, table_name) DO UPDATE SET watermark = EXCLUDED.watermark, synced_at = now();
+`, QualifiedIdent(watermarkTable), strings.Join(values, ", "))
+	_, err := Query(ctx, c, sb.String())
+	return err
+}
@@ -0,0 +1,21 @@
+package copy
+
+import "testing"
+
+func TestChangedSince(t *testing.T) {
+	tests := []struct {
+		name, column, from, to string
+		want                   string
+	}{
+		{"first sync", "updated_at", "", "2026-10-01 02:00:00", `"updated_at" <= '2026-10-01 02:00:00'`},
+		{"since the last watermark", "id", "41", "97", `"id" >= '41' AND "id" <= '97'`},
+		{"quotes are escaped", "note", "it's", "z", `"note" >= 'it''s' AND "note" <= 'z'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ChangedSince(tt.column, tt.from, tt.to); got != tt.want {
+				t.Errorf("ChangedSince() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
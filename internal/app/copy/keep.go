@@ -0,0 +1,179 @@
+package copy
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Keep modes for RestoreTable.
+const (
+	KeepUpsert  = "upsert"  // insert kept rows, overwriting incoming rows with the same primary key
+	KeepReplace = "replace" // discard the incoming rows and put the kept rows back
+)
+
+// RestoreResult reports what RestoreTable did.
+type RestoreResult struct {
+	Inserted  int64 // kept rows inserted
+	Updated   int64 // kept rows that overwrote an incoming row with the same primary key (upsert)
+	Discarded int64 // incoming rows deleted to make room for the kept rows (replace)
+}
+
+// Conflicts is the number of incoming rows the kept rows displaced.
+func (r RestoreResult) Conflicts() int64 { return r.Updated + r.Discarded }
+
+// QualifiedIdent quotes a table name such as "users" or "audit.events" for SQL.
+// Unqualified names are taken to be in WipeSchema.
+func QualifiedIdent(table string) string {
+	schema, name, ok := strings.Cut(table, ".")
+	if !ok {
+		schema, name = WipeSchema, table
+	}
+	return QuoteIdent(schema) + "." + QuoteIdent(name)
+}
+
+// TableExists reports whether table exists on c.
+func TableExists(ctx context.Context, c Conn, table string) (bool, error) {
+	rows, err := Query(ctx, c, fmt.Sprintf("SELECT to_regclass(%s) IS NOT NULL;", QuoteLiteral(QualifiedIdent(table))))
+	if err != nil {
+		return false, err
+	}
+	return len(rows) == 1 && rows[0][0] == "t", nil
+}
+
+// SaveTable writes every row of table on c to file as CSV with a header row.
+func SaveTable(ctx context.Context, c Conn, table, file string) error {
+	return SaveRows(ctx, c, table, "", file)
+}
+
+// SaveRows is SaveTable for the rows matching the SQL condition where; an empty
+// condition saves every row.
+func SaveRows(ctx context.Context, c Conn, table, where, file string) error {
+	query := "SELECT * FROM " + QualifiedIdent(table)
+	if where != "" {
+		query += " WHERE " + where
+	}
+	_, err := Query(ctx, c, fmt.Sprintf(`\copy (%s) TO %s WITH (FORMAT csv, HEADER true)`, query, QuoteLiteral(file)))
+	return err
+}
+
+// PrimaryKey returns the primary key columns of table in key order.
+func PrimaryKey(ctx context.Context, c Conn, table string) ([]string, error) {
+	rows, err := Query(ctx, c, fmt.Sprintf(`SELECT a.attname
+FROM pg_index i JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY (i.indkey)
+WHERE i.indrelid = %s::regclass AND i.indisprimary
+ORDER BY array_position(i.indkey::int2[], a.attnum);`, QuoteLiteral(QualifiedIdent(table))))
+	if err != nil {
+		return nil, err
+	}
+	cols := make([]string, len(rows))
+	for i, r := range rows {
+		cols[i] = r[0]
+	}
+	return cols, nil
+}
+
+// RestoreTable loads rows saved by SaveTable from file back into table on c, in one
+// transaction. With KeepUpsert, rows are merged by primary key and kept rows win; with
+// KeepReplace, the table's current rows are deleted first, in a statement of their own.
+// Only the columns present in the file are written.
+func RestoreTable(ctx context.Context, c Conn, table, file, mode string) (RestoreResult, error) {
+	var res RestoreResult
+	cols, err := csvHeader(file)
+	if err != nil {
+		return res, err
+	}
+	quoted := make([]string, len(cols))
+	for i, col := range cols {
+		quoted[i] = QuoteIdent(col)
+	}
+	colList := strings.Join(quoted, ", ")
+	target := QualifiedIdent(table)
+
+	var sb strings.Builder
+	sb.WriteString("BEGIN;\n")
+	fmt.Fprintf(&sb, "CREATE TEMP TABLE _psqlt_keep AS SELECT %s FROM %s WITH NO DATA;\n", colList, target)
+	fmt.Fprintf(&sb, "\\copy _psqlt_keep (%s) FROM %s WITH (FORMAT csv, HEADER true)\n", colList, QuoteLiteral(file))
+
+	switch mode {
+	case KeepUpsert, "":
+		pk, err := PrimaryKey(ctx, c, table)
+		if err != nil {
+			return res, err
+		}
+		if len(pk) == 0 {
+			return res, fmt.Errorf("%s has no primary key; use mode %q", table, KeepReplace)
+		}
+		pkQuoted := make([]string, len(pk))
+		for i, col := range pk {
+			pkQuoted[i] = QuoteIdent(col)
+		}
+		var sets []string
+		for _, col := range cols {
+			if !slices.Contains(pk, col) {
+				sets = append(sets, fmt.Sprintf("%[1]s = EXCLUDED.%[1]s", QuoteIdent(col)))
+			}
+		}
+		onConflict := "DO NOTHING"
+		if len(sets) > 0 {
+			onConflict = "DO UPDATE SET " + strings.Join(sets, ", ")
+		}
+		// xmax is 0 for freshly inserted rows and non-zero for rows updated on conflict.
+		fmt.Fprintf(&sb, `WITH r AS (
+  INSERT INTO %s (%s) SELECT %s FROM _psqlt_keep
+  ON CONFLICT (%s) %s
+  RETURNING (xmax = 0) AS inserted
+) SELECT count(*) FILTER (WHERE inserted), count(*) FILTER (WHERE NOT inserted), 0 FROM r;
+`, target, colList, colList, strings.Join(pkQuoted, ", "), onConflict)
+	case KeepReplace:
+		// Two statements, as the order of data-modifying CTEs in one statement is undefined.
+		fmt.Fprintf(&sb, `WITH d AS (DELETE FROM %s RETURNING 1) SELECT 0, 0, count(*) FROM d;
+`, target)
+		fmt.Fprintf(&sb, `WITH i AS (INSERT INTO %[1]s (%[2]s) SELECT %[2]s FROM _psqlt_keep RETURNING 1) SELECT count(*), 0, 0 FROM i;
+`, target, colList)
+	default:
+		return res, fmt.Errorf("unknown keep mode %q (want %q or %q)", mode, KeepUpsert, KeepReplace)
+	}
+	sb.WriteString("DROP TABLE _psqlt_keep;\nCOMMIT;\n")
+
+	rows, err := Query(ctx, c, sb.String())
+	if err != nil {
+		return res, err
+	}
+	// Each statement reports inserted, updated and discarded rows.
+	if len(rows) == 0 {
+		return res, fmt.Errorf("unexpected restore output %q", rows)
+	}
+	for _, r := range rows {
+		if len(r) != 3 {
+			return res, fmt.Errorf("unexpected restore output %q", rows)
+		}
+		n := make([]int64, 3)
+		for i, v := range r {
+			if n[i], err = strconv.ParseInt(v, 10, 64); err != nil {
+				return res, fmt.Errorf("unexpected restore output %q", rows)
+			}
+		}
+		res.Inserted += n[0]
+		res.Updated += n[1]
+		res.Discarded += n[2]
+	}
+	return res, nil
+}
+
+func csvHeader(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	header, err := csv.NewReader(f).Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header of %s: %w", file, err)
+	}
+	return header, nil
+}
@@ -0,0 +1,128 @@
+package copy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lockClass namespaces our advisory locks ("psqt"); the second key is a hash of the
+// database name, so each destination database has its own lock.
+const lockClass = 0x70737174
+
+// lockAppPrefix is set as application_name on the locking session so that others
+// can see who holds the lock.
+const lockAppPrefix = "psql-transporter lock: "
+
+// DestLock is a Postgres advisory lock on a destination database. It is held by a
+// dedicated psql session, so it is released when Release is called, when the context
+// is cancelled (which kills psql) or when the process dies.
+type DestLock struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr *bytes.Buffer
+}
+
+// LockedError reports that another run holds the destination lock.
+type LockedError struct {
+	Holder string
+	Since  time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("destination is being refreshed by %s since %s", e.Holder, e.Since.Format("15:04"))
+}
+
+// Lock takes the advisory lock on dst on behalf of holder (e.g. "alice@laptop").
+// If another run holds it, Lock returns a *LockedError, or, when wait is set, calls
+// onWait with that error and blocks until the lock is free or ctx is done.
+func Lock(ctx context.Context, dst Conn, holder string, wait bool, onWait func(*LockedError)) (*DestLock, error) {
+	args := append(dst.baseArgs(), "-X", "-q", "-A", "-t", "-v", "ON_ERROR_STOP=1")
+	cmd := Command(ctx, "psql", args...)
+	cmd.Env = append(dst.env(), "PGAPPNAME="+lockAppPrefix+holder)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	l := &DestLock{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout), stderr: &stderr}
+
+	got, err := l.query(fmt.Sprintf("SELECT pg_try_advisory_lock(%d, hashtext(current_database()));", lockClass))
+	if err != nil {
+		l.Release()
+		return nil, err
+	}
+	if got == "t" {
+		return l, nil
+	}
+
+	locked := &LockedError{Holder: "another session"}
+	row, err := l.query(fmt.Sprintf(`SELECT coalesce(a.application_name, '') || '|' || extract(epoch FROM a.backend_start)::bigint
+FROM pg_locks l JOIN pg_stat_activity a ON a.pid = l.pid
+WHERE l.locktype = 'advisory' AND l.granted AND l.objsubid = 2
+  AND l.classid = %d AND l.objid = hashtext(current_database())::oid
+LIMIT 1;`, lockClass))
+	if err == nil {
+		if app, since, ok := strings.Cut(row, "|"); ok {
+			if h := strings.TrimPrefix(app, lockAppPrefix); h != "" {
+				locked.Holder = h
+			}
+			if sec, err := strconv.ParseInt(since, 10, 64); err == nil {
+				locked.Since = time.Unix(sec, 0)
+			}
+		}
+	}
+	if !wait {
+		l.Release()
+		return nil, locked
+	}
+	if onWait != nil {
+		onWait(locked)
+	}
+	if _, err := l.query(fmt.Sprintf("SELECT 't' FROM (SELECT pg_advisory_lock(%d, hashtext(current_database()))) AS l;", lockClass)); err != nil {
+		l.Release()
+		return nil, err
+	}
+	return l, nil
+}
+
+// query sends one statement to the locking session and returns its single output line.
+func (l *DestLock) query(sql string) (string, error) {
+	if _, err := io.WriteString(l.stdin, sql+"\n"); err != nil {
+		return "", l.failed(err)
+	}
+	line, err := l.stdout.ReadString('\n')
+	if err != nil {
+		return "", l.failed(err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func (l *DestLock) failed(err error) error {
+	if l.stderr.Len() > 0 {
+		return fmt.Errorf("psql lock session failed: %v\n%s", err, l.stderr.String())
+	}
+	return fmt.Errorf("psql lock session failed: %w", err)
+}
+
+// Release ends the locking session, which releases the lock.
+func (l *DestLock) Release() error {
+	io.WriteString(l.stdin, "\\q\n")
+	l.stdin.Close()
+	return l.cmd.Wait()
+}
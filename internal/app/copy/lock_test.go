@@ -0,0 +1,85 @@
+package copy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakePsql answers the statements Lock sends like a psql session would: the try lock
+// with $FAKE_PSQL_TRY and the holder query with $FAKE_PSQL_HOLDER. It records its
+// application name in $FAKE_PSQL_APP.
+const fakePsql = `#!/bin/sh
+echo "$PGAPPNAME" > "$FAKE_PSQL_APP"
+while IFS= read -r line; do
+	case $line in
+	*pg_try_advisory_lock*) echo "$FAKE_PSQL_TRY" ;;
+	"LIMIT 1;") echo "$FAKE_PSQL_HOLDER" ;;
+	*pg_advisory_lock*) echo t ;;
+	'\q') exit 0 ;;
+	esac
+done
+`
+
+func TestLock(t *testing.T) {
+	since := time.Unix(1790820000, 0)
+	holderRow := lockAppPrefix + "alice@laptop|1790820000"
+	tests := []struct {
+		name       string
+		try        string
+		holder     string
+		wait       bool
+		wantLocked *LockedError // the holder reported, if the lock is taken
+	}{
+		{name: "free", try: "t"},
+		{name: "held", try: "f", holder: holderRow, wantLocked: &LockedError{Holder: "alice@laptop", Since: since}},
+		{name: "held by another program", try: "f", holder: "|1790820000", wantLocked: &LockedError{Holder: "another session", Since: since}},
+		{name: "holder unknown", try: "f", holder: "", wantLocked: &LockedError{Holder: "another session"}},
+		{name: "wait for it", try: "f", holder: holderRow, wait: true, wantLocked: &LockedError{Holder: "alice@laptop", Since: since}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "psql"), []byte(fakePsql), 0755); err != nil {
+				t.Fatal(err)
+			}
+			app := filepath.Join(dir, "app")
+			t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+			t.Setenv("FAKE_PSQL_APP", app)
+			t.Setenv("FAKE_PSQL_TRY", tt.try)
+			t.Setenv("FAKE_PSQL_HOLDER", tt.holder)
+
+			var waited *LockedError
+			l, err := Lock(context.Background(), Conn{Host: "localhost", Port: 5432, DBName: "dev"}, "bob@desk", tt.wait, func(le *LockedError) { waited = le })
+			var locked *LockedError
+			switch {
+			case tt.wantLocked == nil || tt.wait:
+				if err != nil {
+					t.Fatalf("Lock() = %v, want the lock", err)
+				}
+				if err := l.Release(); err != nil {
+					t.Errorf("Release() = %v", err)
+				}
+				if tt.wait && (waited == nil || *waited != *tt.wantLocked) {
+					t.Errorf("waited for %+v, want %+v", waited, tt.wantLocked)
+				}
+			case !errors.As(err, &locked):
+				t.Fatalf("Lock() = %v, want a *LockedError", err)
+			case *locked != *tt.wantLocked:
+				t.Errorf("Lock() = %+v, want %+v", locked, tt.wantLocked)
+			}
+
+			b, err := os.ReadFile(app)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(string(b)); got != lockAppPrefix+"bob@desk" {
+				t.Errorf("application_name = %q, want %q", got, lockAppPrefix+"bob@desk")
+			}
+		})
+	}
+}
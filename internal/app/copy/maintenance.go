@@ -0,0 +1,71 @@
+package copy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Post-import maintenance tasks, in the order they are best run.
+const (
+	MaintFixSequences    = "fix_sequences"    // move every owned/identity sequence past its column's max
+	MaintRefreshMatviews = "refresh_matviews" // refresh all materialized views
+	MaintReindex         = "reindex"          // REINDEX DATABASE
+	MaintVacuumAnalyze   = "vacuum_analyze"   // VACUUM (ANALYZE)
+	MaintAnalyze         = "analyze"          // ANALYZE
+)
+
+// MaintenanceTasks lists every task in run order.
+var MaintenanceTasks = []string{MaintFixSequences, MaintRefreshMatviews, MaintReindex, MaintVacuumAnalyze, MaintAnalyze}
+
+// Non-system schemas, for catalog queries that should only touch user objects.
+const userSchemas = "n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg\\_%'"
+
+// Maintain runs one maintenance task on c and returns a short note on what it did.
+func Maintain(ctx context.Context, c Conn, task string) (string, error) {
+	switch task {
+	case MaintAnalyze:
+		_, err := Query(ctx, c, "ANALYZE;")
+		return "", err
+	case MaintVacuumAnalyze:
+		_, err := Query(ctx, c, "VACUUM (ANALYZE);")
+		return "", err
+	case MaintReindex:
+		_, err := Query(ctx, c, "SELECT format('REINDEX DATABASE %I', current_database())\n\\gexec\n")
+		return "", err
+	case MaintRefreshMatviews:
+		// Views restored from a dump were created after the views they depend on, so
+		// refreshing in OID order respects dependencies.
+		rows, err := Query(ctx, c, fmt.Sprintf(`SELECT count(*)
+FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind = 'm' AND %[1]s;
+SELECT format('REFRESH MATERIALIZED VIEW %%I.%%I', n.nspname, c.relname)
+FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind = 'm' AND %[1]s
+ORDER BY c.oid
+\gexec
+`, userSchemas))
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s view(s) refreshed", rows[0][0]), nil
+	case MaintFixSequences:
+		rows, err := Query(ctx, c, fmt.Sprintf(`SELECT format(
+  'SELECT setval(%%L, COALESCE(max(%%I), 1), max(%%I) IS NOT NULL) FROM %%I.%%I',
+  s.oid::regclass::text, a.attname, a.attname, n.nspname, t.relname)
+FROM pg_class s
+JOIN pg_depend d ON d.objid = s.oid AND d.classid = 'pg_class'::regclass
+  AND d.refclassid = 'pg_class'::regclass AND d.deptype IN ('a', 'i')
+JOIN pg_class t ON t.oid = d.refobjid
+JOIN pg_namespace n ON n.oid = t.relnamespace
+JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = d.refobjsubid
+WHERE s.relkind = 'S' AND %s
+\gexec
+`, userSchemas))
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d sequence(s) reset", len(rows)), nil
+	}
+	return "", fmt.Errorf("unknown maintenance task %q (want one of %s)", task, strings.Join(MaintenanceTasks, ", "))
+}
@@ -0,0 +1,201 @@
+package copy
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// MergeResult reports what Merge did to one table.
+type MergeResult struct {
+	Table     string
+	Inserted  int64 // source rows whose key was not in the destination
+	Updated   int64 // destination rows changed to match the source
+	Unchanged int64 // source rows already identical in the destination
+	Deleted   int64 // destination rows whose key was not in the source (delete missing)
+}
+
+// Merge upserts rows saved by SaveTable into the tables on c by primary key, in one
+// transaction. files maps each table to its CSV file. Rows are staged in temp tables,
+// inserted or updated parents first and, with deleteMissing, destination rows whose
+// key is not in the source are deleted children first. Only the columns present in a
+// file are written; rows that would not change are left alone.
+func Merge(ctx context.Context, c Conn, files map[string]string, deleteMissing bool) ([]MergeResult, error) {
+	tables := make([]string, 0, len(files))
+	byName := make(map[string]string, len(files))
+	for table, file := range files {
+		q := qualify(table)
+		tables = append(tables, q)
+		byName[q] = file
+	}
+	slices.Sort(tables)
+	fks, err := ForeignKeys(ctx, c, schemasOf(tables))
+	if err != nil {
+		return nil, err
+	}
+	childrenFirst := TruncateOrder(tables, fks)
+	parentsFirst := slices.Clone(childrenFirst)
+	slices.Reverse(parentsFirst)
+
+	stage := make(map[string]string, len(tables))
+	pks := make(map[string][]string, len(tables))
+	var sb strings.Builder
+	sb.WriteString("BEGIN;\n")
+	for i, table := range parentsFirst {
+		file := byName[table]
+		cols, err := csvHeader(file)
+		if err != nil {
+			return nil, err
+		}
+		pk, err := PrimaryKey(ctx, c, table)
+		if err != nil {
+			return nil, err
+		}
+		if len(pk) == 0 {
+			return nil, fmt.Errorf("%s has no primary key; merge needs one to match rows", table)
+		}
+		for _, col := range pk {
+			if !slices.Contains(cols, col) {
+				return nil, fmt.Errorf("%s: primary key column %q is missing from the source rows", table, col)
+			}
+		}
+		pks[table] = pk
+		stage[table] = fmt.Sprintf("_psqlt_merge_%d", i)
+		eq, err := equalityColumns(ctx, c, table)
+		if err != nil {
+			return nil, err
+		}
+
+		colList := identList(cols)
+		target := QualifiedIdent(table)
+		fmt.Fprintf(&sb, "CREATE TEMP TABLE %s ON COMMIT DROP AS SELECT %s FROM %s WITH NO DATA;\n", stage[table], colList, target)
+		fmt.Fprintf(&sb, "\\copy %s (%s) FROM %s WITH (FORMAT csv, HEADER true)\n", stage[table], colList, QuoteLiteral(file))
+
+		var sets, mine, theirs []string
+		for _, col := range cols {
+			if slices.Contains(pk, col) {
+				continue
+			}
+			sets = append(sets, fmt.Sprintf("%[1]s = EXCLUDED.%[1]s", QuoteIdent(col)))
+			mine = append(mine, comparable("t.", col, eq[col]))
+			theirs = append(theirs, comparable("EXCLUDED.", col, eq[col]))
+		}
+		onConflict := "DO NOTHING"
+		if len(sets) > 0 {
+			onConflict = fmt.Sprintf("DO UPDATE SET %s WHERE ROW(%s) IS DISTINCT FROM ROW(%s)",
+				strings.Join(sets, ", "), strings.Join(mine, ", "), strings.Join(theirs, ", "))
+		}
+		// xmax is 0 for freshly inserted rows and non-zero for rows updated on conflict;
+		// rows skipped by the WHERE clause are not returned at all.
+		fmt.Fprintf(&sb, `WITH r AS (
+  INSERT INTO %s AS t (%s) SELECT %s FROM %s
+  ON CONFLICT (%s) %s
+  RETURNING (xmax = 0) AS inserted
+) SELECT 'merge', %s, count(*) FILTER (WHERE inserted), count(*) FILTER (WHERE NOT inserted), (SELECT count(*) FROM %s) FROM r;
+`, target, colList, colList, stage[table], identList(pk), onConflict, QuoteLiteral(table), stage[table])
+	}
+	if deleteMissing {
+		for _, table := range childrenFirst {
+			fmt.Fprintf(&sb, `WITH d AS (
+  DELETE FROM %s AS t WHERE NOT EXISTS (SELECT 1 FROM %s s WHERE ROW(%s) = ROW(%s))
+  RETURNING 1
+) SELECT 'delete', %s, count(*) FROM d;
+`, QualifiedIdent(table), stage[table], prefixed("s.", pks[table]), prefixed("t.", pks[table]), QuoteLiteral(table))
+		}
+	}
+	sb.WriteString("COMMIT;\n")
+
+	rows, err := Query(ctx, c, sb.String())
+	if err != nil {
+		return nil, err
+	}
+	results := make(map[string]*MergeResult, len(tables))
+	out := make([]MergeResult, 0, len(tables))
+	for _, table := range parentsFirst {
+		out = append(out, MergeResult{Table: table})
+	}
+	for i := range out {
+		results[out[i].Table] = &out[i]
+	}
+	for _, r := range rows {
+		var res *MergeResult
+		if len(r) >= 2 {
+			res = results[r[1]]
+		}
+		switch {
+		case res != nil && r[0] == "merge" && len(r) == 5:
+			res.Inserted, _ = strconv.ParseInt(r[2], 10, 64)
+			res.Updated, _ = strconv.ParseInt(r[3], 10, 64)
+			staged, _ := strconv.ParseInt(r[4], 10, 64)
+			res.Unchanged = staged - res.Inserted - res.Updated
+		case res != nil && r[0] == "delete" && len(r) == 3:
+			res.Deleted, _ = strconv.ParseInt(r[2], 10, 64)
+		default:
+			return nil, fmt.Errorf("unexpected merge output %q", r)
+		}
+	}
+	return out, nil
+}
+
+// equalityColumns reports, for each column of table, whether its type has an =
+// operator. Domains count as their base type and arrays as their element type.
+func equalityColumns(ctx context.Context, c Conn, table string) (map[string]bool, error) {
+	rows, err := Query(ctx, c, fmt.Sprintf(`SELECT a.attname, EXISTS (
+  SELECT 1 FROM pg_operator o WHERE o.oprname = '=' AND o.oprleft = e.oid AND o.oprright = e.oid)
+FROM pg_attribute a
+JOIN pg_type t ON t.oid = a.atttypid
+JOIN pg_type b ON b.oid = CASE WHEN t.typtype = 'd' THEN t.typbasetype ELSE t.oid END
+JOIN pg_type e ON e.oid = CASE WHEN b.typelem <> 0 AND b.typlen = -1 THEN b.typelem ELSE b.oid END
+WHERE a.attrelid = %s::regclass AND a.attnum > 0 AND NOT a.attisdropped;`, QuoteLiteral(QualifiedIdent(table))))
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]bool, len(rows))
+	for _, r := range rows {
+		if len(r) != 2 {
+			return nil, fmt.Errorf("unexpected catalog row %q", r)
+		}
+		out[r[0]] = r[1] == "t"
+	}
+	return out, nil
+}
+
+// comparable returns the column col, prefixed, in a form IS DISTINCT FROM accepts:
+// columns whose type has no = operator, such as json, xml or point, are compared as text.
+func comparable(prefix, col string, hasEquality bool) string {
+	if hasEquality {
+		return prefix + QuoteIdent(col)
+	}
+	return prefix + QuoteIdent(col) + "::text"
+}
+
+// qualify prefixes an unqualified table name with WipeSchema.
+func qualify(table string) string {
+	if strings.Contains(table, ".") {
+		return table
+	}
+	return WipeSchema + "." + table
+}
+
+func schemasOf(tables []string) []string {
+	var schemas []string
+	for _, t := range tables {
+		schema, _, _ := strings.Cut(t, ".")
+		if !slices.Contains(schemas, schema) {
+			schemas = append(schemas, schema)
+		}
+	}
+	return schemas
+}
+
+func identList(cols []string) string { return prefixed("", cols) }
+
+func prefixed(prefix string, cols []string) string {
+	quoted := make([]string, len(cols))
+	for i, col := range cols {
+		quoted[i] = prefix + QuoteIdent(col)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,42 @@
+package copy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestComparable(t *testing.T) {
+	tests := []struct {
+		prefix, col string
+		equality    bool
+		want        string
+	}{
+		{"t.", "name", true, `t."name"`},
+		{"EXCLUDED.", "payload", false, `EXCLUDED."payload"::text`},
+		{"t.", `odd "col"`, false, `t."odd ""col"""::text`},
+	}
+	for _, tt := range tests {
+		if got := comparable(tt.prefix, tt.col, tt.equality); got != tt.want {
+			t.Errorf("comparable(%q, %q, %v) = %s, want %s", tt.prefix, tt.col, tt.equality, got, tt.want)
+		}
+	}
+}
+
+func TestMergeNames(t *testing.T) {
+	if got := qualify("countries"); got != "public.countries" {
+		t.Errorf("qualify(countries) = %q, want public.countries", got)
+	}
+	if got := qualify("billing.plans"); got != "billing.plans" {
+		t.Errorf("qualify(billing.plans) = %q, want it unchanged", got)
+	}
+	got := schemasOf([]string{"public.countries", "billing.plans", "public.users"})
+	if want := []string{"public", "billing"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("schemasOf() = %q, want %q", got, want)
+	}
+	if got, want := prefixed("s.", []string{"id", "tenant id"}), `s."id", s."tenant id"`; got != want {
+		t.Errorf("prefixed() = %s, want %s", got, want)
+	}
+	if got, want := identList([]string{"id"}), `"id"`; got != want {
+		t.Errorf("identList() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,55 @@
+package copy
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// URL returns c as a postgres:// connection URL.
+func (c Conn) URL() string {
+	u := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(c.User, c.Password),
+		Host:   c.Host + ":" + strconv.Itoa(c.Port),
+		Path:   "/" + c.DBName,
+	}
+	if c.SSLMode != "" {
+		u.RawQuery = url.Values{"sslmode": {c.SSLMode}}.Encode()
+	}
+	return u.String()
+}
+
+// Environ returns the environment for a non-psql tool, such as a migration command,
+// to connect to c: the current environment plus the standard PG* variables and DATABASE_URL.
+func (c Conn) Environ() []string {
+	return append(os.Environ(),
+		"PGHOST="+c.Host,
+		"PGPORT="+strconv.Itoa(c.Port),
+		"PGUSER="+c.User,
+		"PGPASSWORD="+c.Password,
+		"PGDATABASE="+c.DBName,
+		"PGSSLMODE="+c.SSLMode,
+		"DATABASE_URL="+c.URL(),
+	)
+}
+
+// AppliedMigrations returns the values of column in a schema_migrations-style table,
+// or nil if the table does not exist.
+func AppliedMigrations(ctx context.Context, c Conn, table, column string) ([]string, error) {
+	exists, err := TableExists(ctx, c, table)
+	if err != nil || !exists {
+		return nil, err
+	}
+	rows, err := Query(ctx, c, fmt.Sprintf("SELECT %s::text FROM %s ORDER BY 1;", QuoteIdent(column), QualifiedIdent(table)))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, len(rows))
+	for i, r := range rows {
+		out[i] = r[0]
+	}
+	return out, nil
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"sync/atomic"
+	"syscall"
 	"time"
 )
 
@@ -39,13 +40,51 @@ func Dump(ctx context.Context, src Conn, outFile string) error {
 	return DumpWithProgress(ctx, src, outFile, nil)
 }
 
+// DumpOptions narrows what DumpWithOptions writes. The zero value dumps the whole
+// database, schema and data.
+type DumpOptions struct {
+	DataOnly   bool     // --data-only
+	SchemaOnly bool     // --schema-only
+	KeepOwners bool     // keep ownership and privileges instead of --no-owner --no-privileges
+	Schemas    []string // -n, one per schema
+	Tables     []string // -t, one per table
+}
+
+func (o DumpOptions) args() []string {
+	var args []string
+	if o.DataOnly {
+		args = append(args, "--data-only")
+	}
+	if o.SchemaOnly {
+		args = append(args, "--schema-only")
+	}
+	for _, s := range o.Schemas {
+		args = append(args, "-n", s)
+	}
+	for _, t := range o.Tables {
+		args = append(args, "-t", t)
+	}
+	return args
+}
+
 // DumpWithProgress runs pg_dump and periodically reports the current output file size
 // via the onSize callback. If onSize is nil, progress is suppressed.
 func DumpWithProgress(ctx context.Context, src Conn, outFile string, onSize func(int64)) error {
-	args := append(src.baseArgs(),
-		"--no-owner", "--no-privileges", "-F", "p", "-f", outFile,
-	)
-	cmd := exec.CommandContext(ctx, "pg_dump", args...)
+	return DumpWithOptions(ctx, src, outFile, DumpOptions{}, onSize)
+}
+
+// DumpArgs returns the pg_dump arguments DumpWithOptions runs with.
+func DumpArgs(src Conn, outFile string, opts DumpOptions) []string {
+	args := append(src.baseArgs(), "-F", "p", "-f", outFile)
+	if !opts.KeepOwners {
+		args = append(args, "--no-owner", "--no-privileges")
+	}
+	return append(args, opts.args()...)
+}
+
+// DumpWithOptions is DumpWithProgress with control over what is dumped.
+func DumpWithOptions(ctx context.Context, src Conn, outFile string, opts DumpOptions, onSize func(int64)) error {
+	cmd := Command(ctx, "pg_dump", DumpArgs(src, outFile, opts)...)
 	cmd.Env = src.env()
 	// Keep pg_dump quiet; we'll manage any UI externally.
 	cmd.Stdout = io.Discard
@@ -92,10 +131,13 @@ func DumpWithProgress(ctx context.Context, src Conn, outFile string, onSize func
 }
 
 func Wipe(ctx context.Context, dst Conn) error {
-	args := append(dst.baseArgs(),
-		"-c", `DROP SCHEMA public CASCADE; CREATE SCHEMA public;`,
-	)
-	cmd := exec.CommandContext(ctx, "psql", args...)
+	return WipeSchemaNamed(ctx, dst, WipeSchema)
+}
+
+// WipeSchemaNamed drops schema on dst with everything in it, if it exists, and
+// creates it again empty. The rest of the database is left alone.
+func WipeSchemaNamed(ctx context.Context, dst Conn, schema string) error {
+	cmd := Command(ctx, "psql", WipeArgs(dst, schema)...)
 	cmd.Env = dst.env()
 	// Hide psql output during wipe as well
 	cmd.Stdout = io.Discard
@@ -110,10 +152,49 @@ func Wipe(ctx context.Context, dst Conn) error {
 	return nil
 }
 
+// WipeArgs returns the psql arguments WipeSchemaNamed runs with.
+func WipeArgs(dst Conn, schema string) []string {
+	return append(dst.baseArgs(),
+		"-c", fmt.Sprintf("DROP SCHEMA IF EXISTS %[1]s CASCADE; CREATE SCHEMA %[1]s;", QuoteIdent(schema)),
+	)
+}
+
+// ImportOptions adjusts how ImportWithOptions loads a file.
+type ImportOptions struct {
+	// ReplicaRole loads with session_replication_role = replica, which skips triggers
+	// and foreign key checks. It needs superuser (or an explicit grant on PG 15+).
+	ReplicaRole bool
+	// StopOnError aborts at the first failing statement instead of carrying on.
+	StopOnError bool
+}
+
+// ImportArgs returns the psql arguments ImportWithOptions runs with; the dump is
+// piped to its stdin.
+func ImportArgs(dst Conn, opts ImportOptions) []string {
+	args := dst.baseArgs()
+	if opts.StopOnError {
+		args = append(args, "-v", "ON_ERROR_STOP=1")
+	}
+	return args
+}
+
+// env returns the environment variables the options add to psql's.
+func (o ImportOptions) env() []string {
+	if o.ReplicaRole {
+		return []string{"PGOPTIONS=-c session_replication_role=replica"}
+	}
+	return nil
+}
+
 // ImportWithProgress streams the SQL file into psql via stdin and periodically
 // reports progress via onProgress(done, total). If onProgress is nil, no progress
 // is reported. Output from psql is suppressed unless there's an error.
 func ImportWithProgress(ctx context.Context, dst Conn, file string, onProgress func(done, total int64)) error {
+	return ImportWithOptions(ctx, dst, file, ImportOptions{}, onProgress)
+}
+
+// ImportWithOptions is ImportWithProgress with control over how the file is loaded.
+func ImportWithOptions(ctx context.Context, dst Conn, file string, opts ImportOptions, onProgress func(done, total int64)) error {
 	f, err := os.Open(file)
 	if err != nil {
 		return err
@@ -126,9 +207,8 @@ func ImportWithProgress(ctx context.Context, dst Conn, file string, onProgress f
 	total := fi.Size()
 
 	// Set up psql reading from stdin so we can measure bytes sent.
-	args := dst.baseArgs()
-	cmd := exec.CommandContext(ctx, "psql", args...)
-	cmd.Env = dst.env()
+	cmd := Command(ctx, "psql", ImportArgs(dst, opts)...)
+	cmd.Env = append(dst.env(), opts.env()...)
 	cmd.Stdout = io.Discard
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
@@ -196,8 +276,24 @@ type writerFunc func(p []byte) (n int, err error)
 
 func (f writerFunc) Write(p []byte) (n int, err error) { return f(p) }
 
+// CommandWaitDelay is how long a cancelled command has to exit before it is killed.
+const CommandWaitDelay = 10 * time.Second
+
+// Command is exec.CommandContext, except that a cancelled command is first asked to
+// stop with SIGTERM, so that psql and pg_dump can close their connections, and only
+// killed if it is still running CommandWaitDelay later.
+func Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	cmd.WaitDelay = CommandWaitDelay
+	return cmd
+}
+
+// DefaultTimeout bounds a single transfer.
+const DefaultTimeout = 30 * time.Minute
+
 func DefaultTimeoutCtx() (context.Context, context.CancelFunc) {
-	return context.WithTimeout(context.Background(), 30*time.Minute)
+	return context.WithTimeout(context.Background(), DefaultTimeout)
 }
 
 // humanSize returns a human-friendly file size using binary units.
@@ -0,0 +1,217 @@
+package copy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// replicationPrefix starts the names of the publications, subscriptions and slots
+// this tool creates, so that leftovers can be found.
+const replicationPrefix = "psqlt_"
+
+// ReplicationName is the name of the publication, subscription and replication slot
+// used to replicate into the destination named dst.
+func ReplicationName(dst string) string {
+	var sb strings.Builder
+	sb.WriteString(replicationPrefix)
+	for _, r := range strings.ToLower(dst) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
+
+// TablesWithoutReplicaIdentity lists the tables in schemas that have neither a primary
+// key nor a replica identity. Once published, UPDATE and DELETE on them fail.
+func TablesWithoutReplicaIdentity(ctx context.Context, c Conn, schemas []string) ([]string, error) {
+	rows, err := Query(ctx, c, fmt.Sprintf(`SELECT n.nspname || '.' || c.relname
+FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind IN ('r', 'p') AND NOT c.relispartition AND n.nspname IN (%s)
+  AND (c.relreplident = 'n' OR (c.relreplident = 'd'
+    AND NOT EXISTS (SELECT 1 FROM pg_index i WHERE i.indrelid = c.oid AND i.indisprimary)))
+ORDER BY 1;`, quoteList(schemas)))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, len(rows))
+	for i, r := range rows {
+		out[i] = r[0]
+	}
+	return out, nil
+}
+
+// CreatePublication publishes tables on c under name.
+func CreatePublication(ctx context.Context, c Conn, name string, tables []string) error {
+	quoted := make([]string, len(tables))
+	for i, t := range tables {
+		quoted[i] = QualifiedIdent(t)
+	}
+	_, err := Query(ctx, c, fmt.Sprintf("CREATE PUBLICATION %s FOR TABLE %s;", QuoteIdent(name), strings.Join(quoted, ", ")))
+	return err
+}
+
+// DropPublication drops the publication name on c, if it exists.
+func DropPublication(ctx context.Context, c Conn, name string) error {
+	_, err := Query(ctx, c, fmt.Sprintf("DROP PUBLICATION IF EXISTS %s;", QuoteIdent(name)))
+	return err
+}
+
+// CreateSubscription subscribes c to the publication name on the server reached by
+// conninfo. The subscription and its replication slot are also called name, and the
+// existing rows are copied first.
+func CreateSubscription(ctx context.Context, c Conn, name, conninfo string) error {
+	// CREATE SUBSCRIPTION can't run in a transaction block; psql -f runs each statement on its own.
+	_, err := Query(ctx, c, fmt.Sprintf("CREATE SUBSCRIPTION %s CONNECTION %s PUBLICATION %s WITH (copy_data = true);",
+		QuoteIdent(name), QuoteLiteral(conninfo), QuoteIdent(name)))
+	return err
+}
+
+// Subscription describes a subscription on the destination.
+type Subscription struct {
+	OID         string
+	Enabled     bool
+	Running     bool   // an apply worker is running
+	LastMessage string // age of the last message from the source, e.g. "00:00:01.2", or ""
+	Tables      []SubscribedTable
+}
+
+// SubscribedTable is the sync state of one table of a subscription.
+type SubscribedTable struct {
+	Table string
+	State string // initializing, copying, syncing or ready
+}
+
+var subscriptionStates = map[string]string{
+	"i": "initializing",
+	"d": "copying",
+	"f": "copied",
+	"s": "syncing",
+	"r": "ready",
+}
+
+// FindSubscription returns the subscription name on c, or nil if there is none.
+func FindSubscription(ctx context.Context, c Conn, name string) (*Subscription, error) {
+	rows, err := Query(ctx, c, fmt.Sprintf(`SELECT s.oid, s.subenabled,
+  EXISTS (SELECT 1 FROM pg_stat_subscription st WHERE st.subid = s.oid AND st.relid IS NULL AND st.pid IS NOT NULL),
+  (SELECT (now() - max(st.last_msg_receipt_time))::text FROM pg_stat_subscription st WHERE st.subid = s.oid)
+FROM pg_subscription s
+WHERE s.subname = %s AND s.subdbid = (SELECT oid FROM pg_database WHERE datname = current_database());`, QuoteLiteral(name)))
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	if len(rows) != 1 || len(rows[0]) != 4 {
+		return nil, fmt.Errorf("unexpected subscription row %q", rows)
+	}
+	sub := &Subscription{OID: rows[0][0], Enabled: rows[0][1] == "t", Running: rows[0][2] == "t", LastMessage: rows[0][3]}
+
+	rows, err = Query(ctx, c, fmt.Sprintf(`SELECT n.nspname || '.' || c.relname, r.srsubstate
+FROM pg_subscription_rel r
+JOIN pg_class c ON c.oid = r.srrelid JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE r.srsubid = %s
+ORDER BY 1;`, sub.OID))
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		if len(r) != 2 {
+			return nil, fmt.Errorf("unexpected subscription row %q", r)
+		}
+		state, ok := subscriptionStates[r[1]]
+		if !ok {
+			state = r[1]
+		}
+		sub.Tables = append(sub.Tables, SubscribedTable{Table: r[0], State: state})
+	}
+	return sub, nil
+}
+
+// DropSubscription removes the subscription name from c without touching the source:
+// it is disabled and detached from its replication slot first, so this works even when
+// the source is unreachable. It returns the subscription's OID, which names the slots
+// of its table syncs, or "" if there was no such subscription.
+func DropSubscription(ctx context.Context, c Conn, name string) (string, error) {
+	sub, err := FindSubscription(ctx, c, name)
+	if err != nil || sub == nil {
+		return "", err
+	}
+	q := QuoteIdent(name)
+	// ALTER ... SET (slot_name = NONE) and DROP SUBSCRIPTION refuse to run inside a transaction.
+	_, err = Query(ctx, c, fmt.Sprintf(`ALTER SUBSCRIPTION %[1]s DISABLE;
+ALTER SUBSCRIPTION %[1]s SET (slot_name = NONE);
+DROP SUBSCRIPTION %[1]s;`, q))
+	return sub.OID, err
+}
+
+// ReplicationSlot is a logical replication slot on the source.
+type ReplicationSlot struct {
+	Name      string
+	Active    bool
+	Retained  int64 // bytes of WAL the slot keeps the source from removing
+	Unflushed int64 // bytes of WAL the subscriber has not confirmed yet (lag)
+}
+
+// ReplicationSlots lists the logical slots on c created for this tool's subscriptions,
+// including the temporary slots of their table syncs.
+func ReplicationSlots(ctx context.Context, c Conn) ([]ReplicationSlot, error) {
+	rows, err := Query(ctx, c, fmt.Sprintf(`SELECT slot_name, active,
+  coalesce(pg_wal_lsn_diff(pg_current_wal_lsn(), restart_lsn), 0)::bigint,
+  coalesce(pg_wal_lsn_diff(pg_current_wal_lsn(), confirmed_flush_lsn), 0)::bigint
+FROM pg_replication_slots
+WHERE slot_type = 'logical' AND database = current_database()
+  AND (slot_name LIKE %s OR slot_name LIKE 'pg\_%%\_sync\_%%')
+ORDER BY 1;`, QuoteLiteral(strings.ReplaceAll(replicationPrefix, "_", `\_`)+"%")))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ReplicationSlot, 0, len(rows))
+	for _, r := range rows {
+		if len(r) != 4 {
+			return nil, fmt.Errorf("unexpected slot row %q", r)
+		}
+		s := ReplicationSlot{Name: r[0], Active: r[1] == "t"}
+		s.Retained, _ = strconv.ParseInt(r[2], 10, 64)
+		s.Unflushed, _ = strconv.ParseInt(r[3], 10, 64)
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// SyncSlotPrefix is the name prefix of the table sync slots of the subscription with
+// the given OID.
+func SyncSlotPrefix(subOID string) string { return "pg_" + subOID + "_sync_" }
+
+// DropReplicationSlots drops the slot name on c and, when subOID is known, the table
+// sync slots of that subscription. Slots still in use are released by terminating
+// the walsender that holds them. It returns the names of the slots it dropped.
+func DropReplicationSlots(ctx context.Context, c Conn, name, subOID string) ([]string, error) {
+	cond := "slot_name = " + QuoteLiteral(name)
+	if subOID != "" {
+		cond += " OR slot_name LIKE " + QuoteLiteral(strings.ReplaceAll(SyncSlotPrefix(subOID), "_", `\_`)+"%")
+	}
+	rows, err := Query(ctx, c, fmt.Sprintf(`SELECT pg_terminate_backend(active_pid) FROM pg_replication_slots
+WHERE active AND slot_type = 'logical' AND (%[1]s);
+DO $$ BEGIN
+  FOR i IN 1..20 LOOP
+    EXIT WHEN NOT EXISTS (SELECT 1 FROM pg_replication_slots WHERE active AND slot_type = 'logical' AND (%[1]s));
+    PERFORM pg_sleep(0.5);
+  END LOOP;
+END $$;
+SELECT slot_name, pg_drop_replication_slot(slot_name) FROM pg_replication_slots
+WHERE slot_type = 'logical' AND (%[1]s);`, cond))
+	if err != nil {
+		return nil, err
+	}
+	var dropped []string
+	for _, r := range rows {
+		if len(r) == 2 {
+			dropped = append(dropped, r[0])
+		}
+	}
+	return dropped, nil
+}
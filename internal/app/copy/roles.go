@@ -0,0 +1,181 @@
+package copy
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// grantable lists the privileges GRANT accepts per kind of object.
+var grantable = map[string][]string{
+	"SCHEMA":    {"ALL", "USAGE", "CREATE"},
+	"TABLES":    {"ALL", "SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER"},
+	"SEQUENCES": {"ALL", "USAGE", "SELECT", "UPDATE"},
+}
+
+func privilegeList(kind string, privs []string) (string, error) {
+	out := make([]string, len(privs))
+	for i, p := range privs {
+		p = strings.ToUpper(strings.TrimSpace(p))
+		if !slices.Contains(grantable[kind], p) {
+			return "", fmt.Errorf("privilege %q can't be granted on %s (want one of %s)",
+				privs[i], strings.ToLower(kind), strings.Join(grantable[kind], ", "))
+		}
+		out[i] = p
+	}
+	return strings.Join(out, ", "), nil
+}
+
+// CheckGrant reports the first privilege that can't be granted on its kind of object.
+func CheckGrant(schemaPrivs, tablePrivs, seqPrivs []string) error {
+	for kind, privs := range map[string][]string{"SCHEMA": schemaPrivs, "TABLES": tablePrivs, "SEQUENCES": seqPrivs} {
+		if _, err := privilegeList(kind, privs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MissingRoles returns the roles that don't exist on c.
+func MissingRoles(ctx context.Context, c Conn, roles []string) ([]string, error) {
+	if len(roles) == 0 {
+		return nil, nil
+	}
+	rows, err := Query(ctx, c, fmt.Sprintf("SELECT r FROM unnest(ARRAY[%s]) r WHERE NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = r) ORDER BY 1;",
+		quoteList(roles)))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, len(rows))
+	for i, r := range rows {
+		out[i] = r[0]
+	}
+	return out, nil
+}
+
+// SetOwner makes owner the owner of schema and of the tables, views, sequences,
+// functions and types in it. Sequences that belong to a column follow their table.
+func SetOwner(ctx context.Context, c Conn, schema, owner string) error {
+	s, o := QuoteLiteral(schema), QuoteLiteral(owner)
+	_, err := Query(ctx, c, fmt.Sprintf(`SELECT format('ALTER SCHEMA %%I OWNER TO %%I', %[1]s, %[2]s)
+\gexec
+SELECT format('ALTER %%s %%I.%%I OWNER TO %%I',
+  CASE c.relkind WHEN 'S' THEN 'SEQUENCE' WHEN 'v' THEN 'VIEW' WHEN 'm' THEN 'MATERIALIZED VIEW'
+    WHEN 'f' THEN 'FOREIGN TABLE' ELSE 'TABLE' END,
+  n.nspname, c.relname, %[2]s)
+FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = %[1]s AND c.relkind IN ('r', 'p', 'v', 'm', 'S', 'f')
+  AND NOT EXISTS (SELECT 1 FROM pg_depend d
+    WHERE d.classid = 'pg_class'::regclass AND d.objid = c.oid AND d.deptype IN ('a', 'i'))
+\gexec
+SELECT format('ALTER %%s %%I.%%I(%%s) OWNER TO %%I',
+  CASE p.prokind WHEN 'a' THEN 'AGGREGATE' WHEN 'p' THEN 'PROCEDURE' ELSE 'FUNCTION' END,
+  n.nspname, p.proname, pg_get_function_identity_arguments(p.oid), %[2]s)
+FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace
+WHERE n.nspname = %[1]s
+  AND NOT EXISTS (SELECT 1 FROM pg_depend d
+    WHERE d.classid = 'pg_proc'::regclass AND d.objid = p.oid AND d.deptype = 'e')
+\gexec
+SELECT format('ALTER %%s %%I.%%I OWNER TO %%I',
+  CASE t.typtype WHEN 'd' THEN 'DOMAIN' ELSE 'TYPE' END, n.nspname, t.typname, %[2]s)
+FROM pg_type t JOIN pg_namespace n ON n.oid = t.typnamespace
+WHERE n.nspname = %[1]s AND t.typtype IN ('c', 'd', 'e', 'r')
+  AND (t.typrelid = 0 OR (SELECT relkind FROM pg_class WHERE oid = t.typrelid) = 'c')
+  AND NOT EXISTS (SELECT 1 FROM pg_depend d
+    WHERE d.classid = 'pg_type'::regclass AND d.objid = t.oid AND d.deptype IN ('e', 'i'))
+\gexec
+`, s, o))
+	return err
+}
+
+// Grant gives role the listed privileges on schema and on all tables and sequences
+// in it, and makes them the default for tables and sequences created there later by
+// owner (or, when owner is empty, by the connecting user).
+func Grant(ctx context.Context, c Conn, schema, owner, role string, schemaPrivs, tablePrivs, seqPrivs []string) error {
+	s, r := QuoteIdent(schema), QuoteIdent(role)
+	defaults := "ALTER DEFAULT PRIVILEGES"
+	if owner != "" {
+		defaults += " FOR ROLE " + QuoteIdent(owner)
+	}
+	defaults += " IN SCHEMA " + s
+
+	var sb strings.Builder
+	if len(schemaPrivs) > 0 {
+		privs, err := privilegeList("SCHEMA", schemaPrivs)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&sb, "GRANT %s ON SCHEMA %s TO %s;\n", privs, s, r)
+	}
+	for _, g := range []struct {
+		kind  string
+		privs []string
+	}{{"TABLES", tablePrivs}, {"SEQUENCES", seqPrivs}} {
+		if len(g.privs) == 0 {
+			continue
+		}
+		privs, err := privilegeList(g.kind, g.privs)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&sb, "GRANT %s ON ALL %s IN SCHEMA %s TO %s;\n", privs, g.kind, s, r)
+		fmt.Fprintf(&sb, "%s GRANT %s ON %s TO %s;\n", defaults, privs, g.kind, r)
+	}
+	if sb.Len() == 0 {
+		return nil
+	}
+	_, err := Query(ctx, c, sb.String())
+	return err
+}
+
+// roleName matches a role name, quoted or not.
+const roleName = `("(?:[^"]|"")*"|[A-Za-z_][A-Za-z0-9_$]*)`
+
+// roleStatements match the single-line dump statements that name roles, capturing
+// the role names: the new owner of ALTER ... OWNER TO, the FOR ROLE of default
+// privileges, and the grantee and grantor of GRANT and REVOKE. Other TO and FROM
+// clauses, such as RENAME TO or SET search_path TO, are left alone.
+var roleStatements = []*regexp.Regexp{
+	regexp.MustCompile(`^ALTER .* OWNER TO ` + roleName + `;\s*$`),
+	regexp.MustCompile(`^ALTER DEFAULT PRIVILEGES FOR ROLE ` + roleName + ` `),
+	regexp.MustCompile(`^(?:ALTER DEFAULT PRIVILEGES .*)?GRANT .* TO ` + roleName +
+		`(?: WITH [A-Z]+ (?:OPTION|TRUE|FALSE))*(?: GRANTED BY ` + roleName + `)?;\s*$`),
+	regexp.MustCompile(`^(?:ALTER DEFAULT PRIVILEGES .*)?REVOKE .* FROM ` + roleName +
+		`(?: GRANTED BY ` + roleName + `)?(?: CASCADE| RESTRICT)?;\s*$`),
+}
+
+// RemapRoles renames roles in the ownership and privilege statements of the plain SQL
+// dump at file, using roles (source name -> destination name).
+func RemapRoles(file string, roles map[string]string) error {
+	if len(roles) == 0 {
+		return nil
+	}
+	return rewriteDump(file, func(line string) string {
+		for _, re := range roleStatements {
+			line = remapMatch(re, line, roles)
+		}
+		return line
+	})
+}
+
+// remapMatch renames the roles that re captures in line.
+func remapMatch(re *regexp.Regexp, line string, roles map[string]string) string {
+	m := re.FindStringSubmatchIndex(line)
+	// Replace from the end so that earlier offsets stay valid.
+	for i := len(m)/2 - 1; i >= 1; i-- {
+		start, end := m[2*i], m[2*i+1]
+		if start < 0 {
+			continue
+		}
+		name := line[start:end]
+		if strings.HasPrefix(name, `"`) {
+			name = strings.ReplaceAll(name[1:len(name)-1], `""`, `"`)
+		}
+		if to, ok := roles[name]; ok {
+			line = line[:start] + QuoteIdent(to) + line[end:]
+		}
+	}
+	return line
+}
@@ -0,0 +1,75 @@
+package copy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemapRoles(t *testing.T) {
+	const dump = `ALTER TABLE public.orders OWNER TO app_owner;
+ALTER SCHEMA public OWNER TO "App Owner";
+ALTER TABLE public.orders RENAME TO app_owner;
+ALTER FUNCTION public.f() SET search_path TO app_owner, public;
+ALTER TABLE public.orders ALTER COLUMN id SET DEFAULT nextval('public.orders_id_seq'::regclass);
+GRANT SELECT ON TABLE public.orders TO reader;
+GRANT ALL ON SCHEMA public TO app_owner WITH GRANT OPTION;
+GRANT SELECT ON TABLE public.orders TO reader GRANTED BY app_owner;
+REVOKE ALL ON SCHEMA public FROM PUBLIC;
+REVOKE ALL ON TABLE public.orders FROM reader;
+ALTER DEFAULT PRIVILEGES FOR ROLE app_owner IN SCHEMA public GRANT SELECT ON TABLES TO reader;
+ALTER DEFAULT PRIVILEGES FOR ROLE app_owner IN SCHEMA public REVOKE ALL ON TABLES FROM reader;
+CREATE FUNCTION public.rotate() RETURNS void
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+ALTER TABLE public.log RENAME TO reader;
+ALTER TABLE public.log OWNER TO reader
+  ;
+END $$;
+COPY public.orders (id, note) FROM stdin;
+1	ALTER TABLE t OWNER TO reader;
+\.
+GRANT app_owner TO unrelated;
+`
+	const want = `ALTER TABLE public.orders OWNER TO "dev_owner";
+ALTER SCHEMA public OWNER TO "dev_owner";
+ALTER TABLE public.orders RENAME TO app_owner;
+ALTER FUNCTION public.f() SET search_path TO app_owner, public;
+ALTER TABLE public.orders ALTER COLUMN id SET DEFAULT nextval('public.orders_id_seq'::regclass);
+GRANT SELECT ON TABLE public.orders TO "dev_reader";
+GRANT ALL ON SCHEMA public TO "dev_owner" WITH GRANT OPTION;
+GRANT SELECT ON TABLE public.orders TO "dev_reader" GRANTED BY "dev_owner";
+REVOKE ALL ON SCHEMA public FROM PUBLIC;
+REVOKE ALL ON TABLE public.orders FROM "dev_reader";
+ALTER DEFAULT PRIVILEGES FOR ROLE "dev_owner" IN SCHEMA public GRANT SELECT ON TABLES TO "dev_reader";
+ALTER DEFAULT PRIVILEGES FOR ROLE "dev_owner" IN SCHEMA public REVOKE ALL ON TABLES FROM "dev_reader";
+CREATE FUNCTION public.rotate() RETURNS void
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+ALTER TABLE public.log RENAME TO reader;
+ALTER TABLE public.log OWNER TO reader
+  ;
+END $$;
+COPY public.orders (id, note) FROM stdin;
+1	ALTER TABLE t OWNER TO reader;
+\.
+GRANT app_owner TO unrelated;
+`
+	file := filepath.Join(t.TempDir(), "dump.sql")
+	if err := os.WriteFile(file, []byte(dump), 0600); err != nil {
+		t.Fatal(err)
+	}
+	roles := map[string]string{"app_owner": "dev_owner", "App Owner": "dev_owner", "reader": "dev_reader"}
+	if err := RemapRoles(file, roles); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("RemapRoles() =\n%s\nwant\n%s", got, want)
+	}
+}
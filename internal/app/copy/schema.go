@@ -0,0 +1,141 @@
+package copy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// schemaNameRE is what a target schema name may look like: a lowercase identifier that
+// never needs quoting, so it can be put into a dump by plain text replacement.
+var schemaNameRE = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// CheckSchemaName reports whether name can be used as a target schema.
+func CheckSchemaName(name string) error {
+	if !schemaNameRE.MatchString(name) {
+		return fmt.Errorf("invalid schema name %q: use lowercase letters, digits and underscores, starting with a letter or underscore", name)
+	}
+	if strings.HasPrefix(name, "pg_") || name == "information_schema" {
+		return fmt.Errorf("schema %q is reserved for the system", name)
+	}
+	return nil
+}
+
+// SchemaInfo describes a user schema of a database.
+type SchemaInfo struct {
+	Name   string
+	Tables int64
+	Bytes  int64 // total size of its tables, indexes and materialized views
+}
+
+// Schemas lists the user schemas of c, without the system ones.
+func Schemas(ctx context.Context, c Conn) ([]SchemaInfo, error) {
+	rows, err := Query(ctx, c, `SELECT n.nspname,
+  count(c.oid) FILTER (WHERE c.relkind IN ('r', 'p') AND NOT c.relispartition),
+  coalesce(sum(pg_total_relation_size(c.oid)) FILTER (WHERE c.relkind IN ('r', 'm')), 0)::bigint
+FROM pg_namespace n LEFT JOIN pg_class c ON c.relnamespace = n.oid
+WHERE n.nspname NOT LIKE 'pg\_%' AND n.nspname <> 'information_schema'
+GROUP BY 1
+ORDER BY 1;`)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]SchemaInfo, 0, len(rows))
+	for _, r := range rows {
+		if len(r) != 3 {
+			return nil, fmt.Errorf("unexpected catalog row %q", r)
+		}
+		s := SchemaInfo{Name: r[0]}
+		s.Tables, _ = strconv.ParseInt(r[1], 10, 64)
+		s.Bytes, _ = strconv.ParseInt(r[2], 10, 64)
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// DropSchema drops schema on c with everything in it.
+func DropSchema(ctx context.Context, c Conn, schema string) error {
+	_, err := Query(ctx, c, fmt.Sprintf("DROP SCHEMA %s CASCADE;", QuoteIdent(schema)))
+	return err
+}
+
+// RewriteSchema rewrites the plain SQL dump at file, made with -n from, so that it
+// restores into the schema to instead: schema-qualified names, schema statements and
+// search_path settings are changed. Table data in COPY blocks is left untouched. to must
+// pass CheckSchemaName.
+func RewriteSchema(file, from, to string) error {
+	if err := CheckSchemaName(to); err != nil {
+		return err
+	}
+	name := regexp.QuoteMeta(from)
+	ident := `(?:` + name + `|"` + name + `")`
+	rules := []struct {
+		re   *regexp.Regexp
+		repl string
+	}{
+		{regexp.MustCompile(`^CREATE SCHEMA ` + ident + `;`), "CREATE SCHEMA IF NOT EXISTS " + to + ";"},
+		// Qualified names, also inside literals such as nextval('public.orders_id_seq').
+		{regexp.MustCompile(`(^|[^A-Za-z0-9_$"])` + ident + `\.`), "${1}" + to + "."},
+		{regexp.MustCompile(`\bSCHEMA ` + ident + `\b`), "SCHEMA " + to},
+		{regexp.MustCompile(`(?i)(search_path\s*(?:=|to)\s*'?)` + ident + `\b`), "${1}" + to},
+		{regexp.MustCompile(`(?i)(set_config\('search_path',\s*')` + name + `\b`), "${1}" + to},
+	}
+	return rewriteDump(file, func(line string) string {
+		for _, rule := range rules {
+			line = rule.re.ReplaceAllString(line, rule.repl)
+		}
+		return line
+	})
+}
+
+// rewriteDump passes every SQL line of the plain dump at file through fn and replaces
+// the file with the result. Table data in COPY blocks is copied unchanged.
+func rewriteDump(file string, fn func(line string) string) error {
+	in, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.CreateTemp(filepath.Dir(file), ".rewrite-*.sql")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+
+	r := bufio.NewReader(in)
+	w := bufio.NewWriter(out)
+	inCopy := false
+	for {
+		line, err := r.ReadString('\n')
+		if line != "" {
+			if inCopy {
+				inCopy = strings.TrimRight(line, "\r\n") != `\.`
+			} else {
+				line = fn(line)
+				inCopy = strings.HasPrefix(line, "COPY ") && strings.HasSuffix(strings.TrimRight(line, "\r\n"), "FROM stdin;")
+			}
+			if _, werr := w.WriteString(line); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), file)
+}
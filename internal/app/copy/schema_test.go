@@ -0,0 +1,72 @@
+package copy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRewriteSchema(t *testing.T) {
+	const dump = `SET statement_timeout = 0;
+SELECT pg_catalog.set_config('search_path', '', false);
+CREATE SCHEMA public;
+COMMENT ON SCHEMA public IS 'standard public schema';
+CREATE TABLE public.orders (
+    id bigint NOT NULL,
+    note text,
+    publication_id bigint
+);
+ALTER TABLE ONLY public.orders ALTER COLUMN id SET DEFAULT nextval('public.orders_id_seq'::regclass);
+CREATE TABLE "public".notes (id bigint);
+SET search_path = public, pg_catalog;
+GRANT USAGE ON SCHEMA public TO app;
+COPY public.orders (id, note, publication_id) FROM stdin;
+1	see public.orders and SCHEMA public	7
+2	SET search_path = public	\N
+\.
+CREATE INDEX orders_note ON public.orders USING btree (note);
+`
+	const want = `SET statement_timeout = 0;
+SELECT pg_catalog.set_config('search_path', '', false);
+CREATE SCHEMA IF NOT EXISTS snap;
+COMMENT ON SCHEMA snap IS 'standard public schema';
+CREATE TABLE snap.orders (
+    id bigint NOT NULL,
+    note text,
+    publication_id bigint
+);
+ALTER TABLE ONLY snap.orders ALTER COLUMN id SET DEFAULT nextval('snap.orders_id_seq'::regclass);
+CREATE TABLE snap.notes (id bigint);
+SET search_path = snap, pg_catalog;
+GRANT USAGE ON SCHEMA snap TO app;
+COPY snap.orders (id, note, publication_id) FROM stdin;
+1	see public.orders and SCHEMA public	7
+2	SET search_path = public	\N
+\.
+CREATE INDEX orders_note ON snap.orders USING btree (note);
+`
+	file := filepath.Join(t.TempDir(), "dump.sql")
+	if err := os.WriteFile(file, []byte(dump), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := RewriteSchema(file, "public", "snap"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("RewriteSchema() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRewriteSchemaRejectsBadNames(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "dump.sql")
+	if err := os.WriteFile(file, []byte("CREATE SCHEMA public;\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := RewriteSchema(file, "public", `snap"; DROP SCHEMA x; --`); err == nil {
+		t.Error("RewriteSchema() accepted an unsafe schema name")
+	}
+}
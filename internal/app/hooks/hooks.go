@@ -0,0 +1,135 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	appcfg "github.com/jayps/psql-transporter/internal/app/config"
+)
+
+// Phases of a transfer at which hooks run.
+const (
+	BeforeExport = "before_export"
+	AfterExport  = "after_export"
+	BeforeWipe   = "before_wipe"
+	AfterImport  = "after_import"
+	OnFailure    = "on_failure"
+)
+
+// Env is what a hook knows about the run. Commands receive it as PSQLT_* environment
+// variables and can use it as a template, e.g. "docker compose -p {{.Destination}} stop";
+// see render.
+type Env struct {
+	Phase       string
+	Source      string // source name, or the dump file being imported
+	Destination string // destination name, or the dump file being written
+	Dump        string // path of the dump file
+	Status      string // running, succeeded or failed
+	Error       string // error message when Status is failed
+}
+
+// envRefs is what templates see: references to the PSQLT_* variables that vars sets.
+var envRefs = Env{
+	Phase:       "${PSQLT_PHASE}",
+	Source:      "${PSQLT_SOURCE}",
+	Destination: "${PSQLT_DESTINATION}",
+	Dump:        "${PSQLT_DUMP}",
+	Status:      "${PSQLT_STATUS}",
+	Error:       "${PSQLT_ERROR}",
+}
+
+func (e Env) vars() []string {
+	return []string{
+		"PSQLT_PHASE=" + e.Phase,
+		"PSQLT_SOURCE=" + e.Source,
+		"PSQLT_DESTINATION=" + e.Destination,
+		"PSQLT_DUMP=" + e.Dump,
+		"PSQLT_STATUS=" + e.Status,
+		"PSQLT_ERROR=" + e.Error,
+	}
+}
+
+// Select returns the hooks for phase from each set, in order.
+func Select(phase string, sets ...appcfg.Hooks) []appcfg.Hook {
+	var out []appcfg.Hook
+	for _, s := range sets {
+		switch phase {
+		case BeforeExport:
+			out = append(out, s.BeforeExport...)
+		case AfterExport:
+			out = append(out, s.AfterExport...)
+		case BeforeWipe:
+			out = append(out, s.BeforeWipe...)
+		case AfterImport:
+			out = append(out, s.AfterImport...)
+		case OnFailure:
+			out = append(out, s.OnFailure...)
+		}
+	}
+	return out
+}
+
+// Run runs each hook with sh, in order, appending its command and output to log. It
+// stops at the first failing hook unless that hook is marked continue_on_error; such
+// failures are returned as warnings instead.
+func Run(ctx context.Context, hooks []appcfg.Hook, env Env, log io.Writer) (warnings []error, err error) {
+	for _, h := range hooks {
+		herr := runOne(ctx, h, env, log)
+		if herr == nil {
+			continue
+		}
+		if !h.ContinueOnError {
+			return warnings, herr
+		}
+		warnings = append(warnings, herr)
+	}
+	return warnings, nil
+}
+
+// render expands the template run for phase. The values of Env come from psql errors,
+// file names and the config, so they are never pasted into the command: each field
+// becomes a reference to its PSQLT_* variable, which sh expands without running
+// anything the value contains.
+func render(phase, run string) (string, error) {
+	tmpl, err := template.New(phase).Option("missingkey=error").Parse(run)
+	if err != nil {
+		return "", err
+	}
+	var command strings.Builder
+	if err := tmpl.Execute(&command, envRefs); err != nil {
+		return "", err
+	}
+	return command.String(), nil
+}
+
+func runOne(ctx context.Context, h appcfg.Hook, env Env, log io.Writer) error {
+	command, err := render(env.Phase, h.Run)
+	if err != nil {
+		return fmt.Errorf("%s hook %q: %w", env.Phase, h.Run, err)
+	}
+
+	fmt.Fprintf(log, "[%s] %s hook: %s\n", time.Now().Format(time.RFC3339), env.Phase, command)
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	// Give an interrupted hook the chance to clean up before it is killed.
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	cmd.WaitDelay = 10 * time.Second
+	cmd.Env = append(os.Environ(), env.vars()...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err = cmd.Run()
+	log.Write(out.Bytes())
+	if err != nil {
+		fmt.Fprintf(log, "[%s] %s hook failed: %v\n", time.Now().Format(time.RFC3339), env.Phase, err)
+		return fmt.Errorf("%s hook %q failed: %w", env.Phase, h.Run, err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	appcfg "github.com/jayps/psql-transporter/internal/app/config"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		run     string
+		want    string
+		wantErr string
+	}{
+		{name: "plain", run: "docker compose -p dev stop", want: "docker compose -p dev stop"},
+		{name: "field", run: "docker compose -p {{.Destination}} stop", want: "docker compose -p ${PSQLT_DESTINATION} stop"},
+		{
+			name: "every field",
+			run:  `notify "{{.Phase}} {{.Source}} {{.Destination}} {{.Dump}} {{.Status}} {{.Error}}"`,
+			want: `notify "${PSQLT_PHASE} ${PSQLT_SOURCE} ${PSQLT_DESTINATION} ${PSQLT_DUMP} ${PSQLT_STATUS} ${PSQLT_ERROR}"`,
+		},
+		{name: "unknown field", run: "echo {{.Database}}", wantErr: "can't evaluate field Database"},
+		{name: "bad template", run: "echo {{.Source", wantErr: "unclosed action"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := render(OnFailure, tt.run)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("render() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunDoesNotExecuteValues(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	env := Env{
+		Phase:       OnFailure,
+		Source:      "staging$(touch " + filepath.Join(dir, "source") + ")",
+		Destination: "dev`touch " + filepath.Join(dir, "destination") + "`",
+		Status:      "failed",
+		Error:       `ERROR: relation "x'; touch ` + filepath.Join(dir, "x") + ` #" does not exist`,
+	}
+	hooks := []appcfg.Hook{
+		{Run: `echo "{{.Source}} {{.Destination}} {{.Error}}" > ` + out},
+		{Run: `echo {{.Error}} >> ` + out},
+	}
+	var log bytes.Buffer
+	if _, err := Run(context.Background(), hooks, env, &log); err != nil {
+		t.Fatalf("Run() = %v\n%s", err, log.String())
+	}
+	for _, name := range []string{"source", "destination", "x"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			t.Errorf("a hook value was executed: %s exists", name)
+		}
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := env.Source + " " + env.Destination + " " + env.Error + "\n"; !strings.HasPrefix(string(got), want) {
+		t.Errorf("hook wrote %q, want it to start with %q", got, want)
+	}
+}
@@ -0,0 +1,82 @@
+package plan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Version is the plan file format this build writes and applies.
+const Version = 1
+
+// Plan is a transfer resolved ahead of time: what runs, against what, with which
+// commands. It holds no secrets, so it can be reviewed and kept as a record.
+type Plan struct {
+	Version     int       `json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	CreatedBy   string    `json:"created_by"`
+	Recipe      string    `json:"recipe,omitempty"`
+	Mode        string    `json:"mode"`
+	IntoSchema  string    `json:"into_schema,omitempty"`
+	Config      string    `json:"config"` // Digest of the settings the run depends on
+	Source      Endpoint  `json:"source"`
+	Destination Endpoint  `json:"destination"`
+	Steps       []Step    `json:"steps"`
+}
+
+// Endpoint is the source or destination database of a plan.
+type Endpoint struct {
+	Name        string `json:"name"`
+	Host        string `json:"host"`
+	Port        int    `json:"port"`
+	DBName      string `json:"dbname"`
+	Fingerprint string `json:"fingerprint"` // database identity and schema shape when planned
+	Bytes       int64  `json:"bytes"`       // size of the tables the transfer reads or replaces
+}
+
+// Step is one thing the transfer does.
+type Step struct {
+	Name    string `json:"name"`
+	Command string `json:"command,omitempty"` // with secrets redacted
+	Target  string `json:"target,omitempty"`
+	Bytes   int64  `json:"estimated_bytes,omitempty"`
+}
+
+// Save writes p to path as indented JSON.
+func Save(path string, p Plan) error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// Load reads the plan at path.
+func Load(path string) (Plan, error) {
+	var p Plan
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(b, &p); err != nil {
+		return p, fmt.Errorf("%s: %w", path, err)
+	}
+	if p.Version != Version {
+		return p, fmt.Errorf("%s is plan version %d, but this build applies version %d", path, p.Version, Version)
+	}
+	return p, nil
+}
+
+// Digest hashes the JSON encoding of v, so that a plan can tell whether settings
+// changed since it was made.
+func Digest(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,240 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	appcfg "github.com/jayps/psql-transporter/internal/app/config"
+)
+
+// File is the name rules use for a dump file on either side of a transfer.
+const File = "file"
+
+// Request describes a transfer about to run.
+type Request struct {
+	Source      string // source name, or File
+	Destination string // destination name, or File
+	Mode        string // transfer mode; empty means appcfg.ModeFull
+	User        string // OS user running the transfer
+	Now         time.Time
+}
+
+// wipes reports whether the request wipes a database.
+func (r Request) wipes() bool { return r.Destination != File && Wipes(r.Mode) }
+
+// Wipes reports whether a transfer in mode drops or truncates what the destination
+// held: full refreshes, which dump files are always loaded with, and data-only
+// loads. Merge and incremental runs only upsert, so wipe windows don't apply to them.
+func Wipes(mode string) bool {
+	return mode == "" || mode == appcfg.ModeFull || mode == appcfg.ModeDataOnly
+}
+
+// Violation is returned by Check when a rule blocks a request.
+type Violation struct {
+	Rule   string // the rule as written in the config, e.g. "policies.no_export: [prod]"
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("blocked by policy: %s\n  rule: %s", v.Reason, v.Rule)
+}
+
+// Check evaluates every policy against r and returns the first *Violation, or a plain
+// error if a rule is malformed. It must pass before anything is exported or wiped.
+func Check(p appcfg.Policies, r Request) error {
+	if r.Source != File && matchAny(p.NoExport, r.Source) {
+		return &Violation{
+			Rule:   rule("policies.no_export", p.NoExport),
+			Reason: fmt.Sprintf("source %q may not be exported", r.Source),
+		}
+	}
+
+	if len(p.AllowedPairs) > 0 {
+		allowed := false
+		for _, pr := range p.AllowedPairs {
+			if pairMatches(pr, r) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return &Violation{
+				Rule:   rule("policies.allowed_pairs", p.AllowedPairs),
+				Reason: fmt.Sprintf("%s → %s is not an allowed pair", r.Source, r.Destination),
+			}
+		}
+	}
+
+	if r.wipes() {
+		if err := CheckWipeWindows(p, r.Destination, r.Now); err != nil {
+			return err
+		}
+	}
+
+	if len(p.Users) > 0 {
+		allowed := false
+		for _, u := range p.Users {
+			if matchAny(u.Users, r.User) && matchAny(u.Sources, r.Source) && matchAny(u.Destinations, r.Destination) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return &Violation{
+				Rule:   rule("policies.users", p.Users),
+				Reason: fmt.Sprintf("user %q may not transfer %s → %s", r.User, r.Source, r.Destination),
+			}
+		}
+	}
+	return nil
+}
+
+// CheckWipeWindows reports a *Violation if no wipe window covering destination is
+// open at now. Check includes it; transfers run it again once they hold the
+// destination lock, which they may have waited for.
+func CheckWipeWindows(p appcfg.Policies, destination string, now time.Time) error {
+	var windows []int
+	for i, w := range p.WipeWindows {
+		if !matchAny(w.Destinations, destination) {
+			continue
+		}
+		windows = append(windows, i)
+		ok, err := windowContains(w, now)
+		if err != nil {
+			return fmt.Errorf("policies.wipe_windows[%d]: %w", i, err)
+		}
+		if ok {
+			return nil
+		}
+	}
+	if len(windows) == 0 {
+		return nil
+	}
+	i := windows[0]
+	return &Violation{
+		Rule:   rule(fmt.Sprintf("policies.wipe_windows[%d]", i), p.WipeWindows[i]),
+		Reason: fmt.Sprintf("%q may not be wiped at %s", destination, now.Format("Mon 15:04")),
+	}
+}
+
+func pairMatches(pr appcfg.PairRule, r Request) bool {
+	return matchAny(pr.From, r.Source) && matchAny(pr.To, r.Destination)
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// windowContains reports whether t falls inside w.
+func windowContains(w appcfg.WipeWindow, t time.Time) (bool, error) {
+	if len(w.Days) > 0 {
+		ok := false
+		for _, d := range w.Days {
+			in, err := dayMatches(d, t.Weekday())
+			if err != nil {
+				return false, err
+			}
+			if in {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	if w.Hours == "" {
+		return true, nil
+	}
+	from, to, ok := strings.Cut(w.Hours, "-")
+	if !ok {
+		return false, fmt.Errorf("hours %q: want a range like 09:00-18:00", w.Hours)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return false, err
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return false, err
+	}
+	now := t.Hour()*60 + t.Minute()
+	if start <= end {
+		return now >= start && now < end, nil
+	}
+	// overnight window, e.g. 22:00-06:00
+	return now >= start || now < end, nil
+}
+
+// dayMatches matches a day name ("mon") or an inclusive range ("mon-fri", "fri-mon").
+func dayMatches(spec string, day time.Weekday) (bool, error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	from, to, isRange := strings.Cut(spec, "-")
+	start, ok := weekdays[from]
+	if !ok {
+		return false, fmt.Errorf("unknown day %q", from)
+	}
+	if !isRange {
+		return day == start, nil
+	}
+	end, ok := weekdays[to]
+	if !ok {
+		return false, fmt.Errorf("unknown day %q", to)
+	}
+	if start <= end {
+		return day >= start && day <= end, nil
+	}
+	return day >= start || day <= end, nil
+}
+
+// parseClock parses "9", "09" or "09:30" into minutes since midnight.
+func parseClock(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	hh, mm, hasMinutes := strings.Cut(s, ":")
+	h, err := strconv.Atoi(hh)
+	if err != nil || h < 0 || h > 24 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	m := 0
+	if hasMinutes {
+		if m, err = strconv.Atoi(mm); err != nil || m < 0 || m > 59 {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+	}
+	return h*60 + m, nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if p == "*" || p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// rule renders a config fragment on one line for violation messages.
+func rule(path string, v any) string {
+	var n yaml.Node
+	if err := n.Encode(v); err != nil {
+		return path
+	}
+	flowStyle(&n)
+	b, err := yaml.Marshal(&n)
+	if err != nil {
+		return path
+	}
+	return path + ": " + strings.TrimSpace(string(b))
+}
+
+func flowStyle(n *yaml.Node) {
+	n.Style |= yaml.FlowStyle
+	for _, c := range n.Content {
+		flowStyle(c)
+	}
+}
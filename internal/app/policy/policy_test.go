@@ -0,0 +1,146 @@
+package policy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	appcfg "github.com/jayps/psql-transporter/internal/app/config"
+)
+
+// at returns a time on the week of Mon 2026-10-19 in the local time zone.
+func at(day time.Weekday, clock string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", "2026-10-19 "+clock, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return t.AddDate(0, 0, (int(day)-int(time.Monday)+7)%7)
+}
+
+func TestDayMatches(t *testing.T) {
+	tests := []struct {
+		spec string
+		day  time.Weekday
+		want bool
+	}{
+		{"mon", time.Monday, true},
+		{"Mon", time.Monday, true},
+		{"mon", time.Tuesday, false},
+		{"mon-fri", time.Wednesday, true},
+		{"mon-fri", time.Friday, true},
+		{"mon-fri", time.Saturday, false},
+		{"fri-mon", time.Sunday, true},
+		{"fri-mon", time.Monday, true},
+		{"fri-mon", time.Wednesday, false},
+		{"sat-sun", time.Sunday, true},
+	}
+	for _, tt := range tests {
+		got, err := dayMatches(tt.spec, tt.day)
+		if err != nil {
+			t.Fatalf("dayMatches(%q): %v", tt.spec, err)
+		}
+		if got != tt.want {
+			t.Errorf("dayMatches(%q, %s) = %v, want %v", tt.spec, tt.day, got, tt.want)
+		}
+	}
+	for _, spec := range []string{"monday", "mon-", "xyz-fri"} {
+		if _, err := dayMatches(spec, time.Monday); err == nil {
+			t.Errorf("dayMatches(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	office := appcfg.WipeWindow{Days: []string{"mon-fri"}, Hours: "09:00-18:00"}
+	night := appcfg.WipeWindow{Hours: "22:00-06:00"}
+	weekendNights := appcfg.WipeWindow{Days: []string{"sat", "sun"}, Hours: "22-6"}
+	tests := []struct {
+		name string
+		w    appcfg.WipeWindow
+		t    time.Time
+		want bool
+	}{
+		{"office hours", office, at(time.Tuesday, "10:30"), true},
+		{"start is inside", office, at(time.Tuesday, "09:00"), true},
+		{"end is outside", office, at(time.Tuesday, "18:00"), false},
+		{"before office hours", office, at(time.Tuesday, "08:59"), false},
+		{"weekend", office, at(time.Saturday, "10:30"), false},
+		{"late evening", night, at(time.Tuesday, "23:15"), true},
+		{"after midnight", night, at(time.Wednesday, "05:59"), true},
+		{"morning", night, at(time.Wednesday, "06:00"), false},
+		{"afternoon", night, at(time.Wednesday, "14:00"), false},
+		{"saturday night", weekendNights, at(time.Saturday, "23:00"), true},
+		{"friday night", weekendNights, at(time.Friday, "23:00"), false},
+		{"any time", appcfg.WipeWindow{Days: []string{"wed"}}, at(time.Wednesday, "03:00"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := windowContains(tt.w, tt.t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("windowContains(%+v, %s) = %v, want %v", tt.w, tt.t.Format("Mon 15:04"), got, tt.want)
+			}
+		})
+	}
+	for _, hours := range []string{"9-", "09:00", "25:00-26:00", "09:61-10:00"} {
+		if _, err := windowContains(appcfg.WipeWindow{Hours: hours}, at(time.Monday, "10:00")); err == nil {
+			t.Errorf("hours %q accepted, want an error", hours)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	p := appcfg.Policies{
+		NoExport:     []string{"prod"},
+		AllowedPairs: []appcfg.PairRule{{From: []string{"staging", File}, To: []string{"dev", "qa", File}}, {From: []string{"prod"}, To: []string{File}}},
+		WipeWindows:  []appcfg.WipeWindow{{Destinations: []string{"qa"}, Days: []string{"mon-fri"}, Hours: "09:00-18:00"}},
+		Users: []appcfg.UserRule{
+			{Users: []string{"alice"}, Sources: []string{"*"}, Destinations: []string{"*"}},
+			{Users: []string{"*"}, Sources: []string{"staging", File}, Destinations: []string{"dev", File}},
+		},
+	}
+	weekday, weekend := at(time.Tuesday, "10:00"), at(time.Saturday, "10:00")
+	tests := []struct {
+		name    string
+		r       Request
+		blocked string // the rule expected to block r, if any
+	}{
+		{"allowed", Request{Source: "staging", Destination: "dev", User: "bob", Now: weekend}, ""},
+		{"no export", Request{Source: "prod", Destination: File, User: "alice", Now: weekday}, "policies.no_export"},
+		{"pair not allowed", Request{Source: "dev", Destination: "qa", User: "alice", Now: weekday}, "policies.allowed_pairs"},
+		{"inside the wipe window", Request{Source: "staging", Destination: "qa", User: "alice", Now: weekday}, ""},
+		{"outside the wipe window", Request{Source: "staging", Destination: "qa", User: "alice", Now: weekend}, "policies.wipe_windows[0]"},
+		{"a dump file is not wiped", Request{Source: "staging", Destination: File, User: "bob", Now: weekend}, ""},
+		{"a merge is not a wipe", Request{Source: "staging", Destination: "qa", Mode: appcfg.ModeMerge, User: "alice", Now: weekend}, ""},
+		{"an incremental sync is not a wipe", Request{Source: "staging", Destination: "qa", Mode: appcfg.ModeIncremental, User: "alice", Now: weekend}, ""},
+		{"a data-only load is a wipe", Request{Source: "staging", Destination: "qa", Mode: appcfg.ModeDataOnly, User: "alice", Now: weekend}, "policies.wipe_windows[0]"},
+		{"a merge still needs an allowed pair", Request{Source: "dev", Destination: "qa", Mode: appcfg.ModeMerge, User: "alice", Now: weekday}, "policies.allowed_pairs"},
+		{"user not allowed", Request{Source: "staging", Destination: "qa", User: "bob", Now: weekday}, "policies.users"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(p, tt.r)
+			var v *Violation
+			switch {
+			case tt.blocked == "" && err != nil:
+				t.Errorf("Check() = %v, want it allowed", err)
+			case tt.blocked != "" && !errors.As(err, &v):
+				t.Errorf("Check() = %v, want a violation of %s", err, tt.blocked)
+			case tt.blocked != "" && !strings.HasPrefix(v.Rule, tt.blocked):
+				t.Errorf("Check() blocked by %s, want %s", v.Rule, tt.blocked)
+			}
+		})
+	}
+}
+
+func TestCheckMalformedWindow(t *testing.T) {
+	p := appcfg.Policies{WipeWindows: []appcfg.WipeWindow{{Destinations: []string{"*"}, Days: []string{"someday"}}}}
+	err := Check(p, Request{Source: "staging", Destination: "dev", Now: at(time.Monday, "10:00")})
+	var v *Violation
+	if err == nil || errors.As(err, &v) {
+		t.Errorf("Check() = %v, want a plain error for the malformed rule", err)
+	}
+}
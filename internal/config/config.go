@@ -2,13 +2,70 @@ package config
 
 import appcfg "github.com/jayps/psql-transporter/internal/app/config"
 
-const DefaultFile = appcfg.DefaultFile
+const (
+	DefaultFile          = appcfg.DefaultFile
+	CurrentVersion       = appcfg.CurrentVersion
+	ModeFull             = appcfg.ModeFull
+	ModeDataOnly         = appcfg.ModeDataOnly
+	ModeMerge            = appcfg.ModeMerge
+	ModeIncremental      = appcfg.ModeIncremental
+	StateInFile          = appcfg.StateInFile
+	StateInTable         = appcfg.StateInTable
+	DefaultMaintenanceDB = appcfg.DefaultMaintenanceDB
+	RunRunning           = appcfg.RunRunning
+	RunSucceeded         = appcfg.RunSucceeded
+	RunFailed            = appcfg.RunFailed
+)
+
+var Modes = appcfg.Modes
 
 type (
-	Source = appcfg.Source
-	Config = appcfg.Config
+	Source          = appcfg.Source
+	Config          = appcfg.Config
+	MigrationResult = appcfg.MigrationResult
+	Encryption      = appcfg.Encryption
+	Environment     = appcfg.Environment
+	Risk            = appcfg.Risk
+	Policies        = appcfg.Policies
+	PairRule        = appcfg.PairRule
+	UserRule        = appcfg.UserRule
+	KeepTable       = appcfg.KeepTable
+	SQLStep         = appcfg.SQLStep
+	Hooks           = appcfg.Hooks
+	Hook            = appcfg.Hook
+	Migrations      = appcfg.Migrations
+	Merge           = appcfg.Merge
+	Incremental     = appcfg.Incremental
+	Watermark       = appcfg.Watermark
+	State           = appcfg.State
+	Grant           = appcfg.Grant
+	Globals         = appcfg.Globals
+	Recipe          = appcfg.Recipe
+	Schedule        = appcfg.Schedule
+	ScheduleRun     = appcfg.ScheduleRun
 )
 
 func EnsureExists(root string) (string, bool, error) { return appcfg.EnsureExists(root) }
 func Load(path string) (Config, error)               { return appcfg.Load(path) }
+func LoadRaw(path string) (Config, error)            { return appcfg.LoadRaw(path) }
 func Save(path string, c Config) error               { return appcfg.Save(path, c) }
+func Migrate(path string, dryRun bool) (MigrationResult, error) {
+	return appcfg.Migrate(path, dryRun)
+}
+func ParseMode(m string) (string, error)        { return appcfg.ParseMode(m) }
+func SyncKey(source, destination string) string { return appcfg.SyncKey(source, destination) }
+func LoadState(path string) (State, error)      { return appcfg.LoadState(path) }
+func SaveState(path string, st State) error     { return appcfg.SaveState(path, st) }
+func Layers(path string) ([]string, error)      { return appcfg.Layers(path) }
+func RedactedFile(path string) ([]byte, error)  { return appcfg.RedactedFile(path) }
+func LocalPath(path string) string              { return appcfg.LocalPath(path) }
+func UpdateState(path string, update func(*State)) error {
+	return appcfg.UpdateState(path, update)
+}
+func EncryptFile(path string, recipients []string) (int, error) {
+	return appcfg.EncryptFile(path, recipients)
+}
+func DecryptFile(path, identityFile string) (int, error) {
+	return appcfg.DecryptFile(path, identityFile)
+}
+func IdentityPath(c Config) (string, error) { return appcfg.IdentityPath(c) }
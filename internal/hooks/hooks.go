@@ -0,0 +1,26 @@
+package hooks
+
+import (
+	"context"
+	"io"
+
+	apphooks "github.com/jayps/psql-transporter/internal/app/hooks"
+	"github.com/jayps/psql-transporter/internal/config"
+)
+
+const (
+	BeforeExport = apphooks.BeforeExport
+	AfterExport  = apphooks.AfterExport
+	BeforeWipe   = apphooks.BeforeWipe
+	AfterImport  = apphooks.AfterImport
+	OnFailure    = apphooks.OnFailure
+)
+
+type Env = apphooks.Env
+
+func Select(phase string, sets ...config.Hooks) []config.Hook { return apphooks.Select(phase, sets...) }
+
+// Run runs hooks in order, logging their output
+func Run(ctx context.Context, hooks []config.Hook, env Env, log io.Writer) ([]error, error) {
+	return apphooks.Run(ctx, hooks, env, log)
+}
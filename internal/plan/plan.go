@@ -0,0 +1,15 @@
+package plan
+
+import appplan "github.com/jayps/psql-transporter/internal/app/plan"
+
+const Version = appplan.Version
+
+type (
+	Plan     = appplan.Plan
+	Endpoint = appplan.Endpoint
+	Step     = appplan.Step
+)
+
+func Save(path string, p Plan) error { return appplan.Save(path, p) }
+func Load(path string) (Plan, error) { return appplan.Load(path) }
+func Digest(v any) (string, error)   { return appplan.Digest(v) }
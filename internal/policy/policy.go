@@ -0,0 +1,22 @@
+package policy
+
+import (
+	"time"
+
+	apppolicy "github.com/jayps/psql-transporter/internal/app/policy"
+	"github.com/jayps/psql-transporter/internal/config"
+)
+
+const File = apppolicy.File
+
+type (
+	Request   = apppolicy.Request
+	Violation = apppolicy.Violation
+)
+
+func Check(p config.Policies, r Request) error { return apppolicy.Check(p, r) }
+func Wipes(mode string) bool                   { return apppolicy.Wipes(mode) }
+
+func CheckWipeWindows(p config.Policies, destination string, now time.Time) error {
+	return apppolicy.CheckWipeWindows(p, destination, now)
+}
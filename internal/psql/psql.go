@@ -2,11 +2,39 @@ package psql
 
 import (
 	"context"
+	"os/exec"
+
 	appcopy "github.com/jayps/psql-transporter/internal/app/copy"
 )
 
+const (
+	WipeSchema     = appcopy.WipeSchema
+	DefaultTimeout = appcopy.DefaultTimeout
+	KeepUpsert     = appcopy.KeepUpsert
+	KeepReplace    = appcopy.KeepReplace
+)
+
+var MaintenanceTasks = appcopy.MaintenanceTasks
+
 type (
-	Conn = appcopy.Conn
+	Conn            = appcopy.Conn
+	TableInfo       = appcopy.TableInfo
+	RestoreResult   = appcopy.RestoreResult
+	DumpOptions     = appcopy.DumpOptions
+	ImportOptions   = appcopy.ImportOptions
+	Column          = appcopy.Column
+	ForeignKey      = appcopy.ForeignKey
+	MergeResult     = appcopy.MergeResult
+	Subscription    = appcopy.Subscription
+	SubscribedTable = appcopy.SubscribedTable
+	ReplicationSlot = appcopy.ReplicationSlot
+	SchemaInfo      = appcopy.SchemaInfo
+	GlobalsFilter   = appcopy.GlobalsFilter
+	GlobalsPlan     = appcopy.GlobalsPlan
+	GlobalRole      = appcopy.GlobalRole
+	DatabaseInfo    = appcopy.DatabaseInfo
+	DestLock        = appcopy.DestLock
+	LockedError     = appcopy.LockedError
 )
 
 func Dump(ctx context.Context, src Conn, outFile string) error {
@@ -18,11 +46,231 @@ func DumpWithProgress(ctx context.Context, src Conn, outFile string, onSize func
 	return appcopy.DumpWithProgress(ctx, src, outFile, onSize)
 }
 
+// DumpWithOptions is DumpWithProgress with control over what is dumped
+func DumpWithOptions(ctx context.Context, src Conn, outFile string, opts DumpOptions, onSize func(int64)) error {
+	return appcopy.DumpWithOptions(ctx, src, outFile, opts, onSize)
+}
+
+// ImportWithOptions is ImportWithProgress with control over how the file is loaded
+func ImportWithOptions(ctx context.Context, dst Conn, file string, opts ImportOptions, onProgress func(done, total int64)) error {
+	return appcopy.ImportWithOptions(ctx, dst, file, opts, onProgress)
+}
+
 // ImportWithProgress exposes import progress via callback (done, total)
 func ImportWithProgress(ctx context.Context, dst Conn, file string, onProgress func(done, total int64)) error {
 	return appcopy.ImportWithProgress(ctx, dst, file, onProgress)
 }
 
+// Lock takes the advisory lock that keeps two runs from refreshing dst at once
+func Lock(ctx context.Context, dst Conn, holder string, wait bool, onWait func(*LockedError)) (*DestLock, error) {
+	return appcopy.Lock(ctx, dst, holder, wait, onWait)
+}
+
 func Wipe(ctx context.Context, dst Conn) error                 { return appcopy.Wipe(ctx, dst) }
 func Import(ctx context.Context, dst Conn, file string) error  { return appcopy.Import(ctx, dst, file) }
 func DefaultTimeoutCtx() (context.Context, context.CancelFunc) { return appcopy.DefaultTimeoutCtx() }
+
+// Command is exec.CommandContext that stops a cancelled command with SIGTERM before killing it
+func Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return appcopy.Command(ctx, name, args...)
+}
+
+// Query runs one SQL statement and returns its rows
+func Query(ctx context.Context, c Conn, sql string) ([][]string, error) {
+	return appcopy.Query(ctx, c, sql)
+}
+
+// Tables lists the tables in the given schemas with size and row estimates
+func Tables(ctx context.Context, c Conn, schemas []string) ([]TableInfo, error) {
+	return appcopy.Tables(ctx, c, schemas)
+}
+
+func DumpTables(file string) ([]string, error) { return appcopy.DumpTables(file) }
+func QuoteLiteral(s string) string             { return appcopy.QuoteLiteral(s) }
+func QuoteIdent(s string) string               { return appcopy.QuoteIdent(s) }
+
+// ExecFile runs a SQL script file
+func ExecFile(ctx context.Context, c Conn, file string) error { return appcopy.ExecFile(ctx, c, file) }
+
+// Assert runs a query that must return a non-empty, non-false first value
+func Assert(ctx context.Context, c Conn, query string) (ok bool, result string, err error) {
+	return appcopy.Assert(ctx, c, query)
+}
+
+// AppliedMigrations lists the versions recorded in a schema_migrations-style table
+func AppliedMigrations(ctx context.Context, c Conn, table, column string) ([]string, error) {
+	return appcopy.AppliedMigrations(ctx, c, table, column)
+}
+
+// Maintain runs one post-import maintenance task
+func Maintain(ctx context.Context, c Conn, task string) (string, error) {
+	return appcopy.Maintain(ctx, c, task)
+}
+
+// SaveTable writes the rows of table to a CSV file
+func SaveTable(ctx context.Context, c Conn, table, file string) error {
+	return appcopy.SaveTable(ctx, c, table, file)
+}
+
+// SaveRows writes the rows of table matching a condition to a CSV file
+func SaveRows(ctx context.Context, c Conn, table, where, file string) error {
+	return appcopy.SaveRows(ctx, c, table, where, file)
+}
+
+// RestoreTable puts rows saved by SaveTable back into table
+func RestoreTable(ctx context.Context, c Conn, table, file, mode string) (RestoreResult, error) {
+	return appcopy.RestoreTable(ctx, c, table, file, mode)
+}
+
+func TableExists(ctx context.Context, c Conn, table string) (bool, error) {
+	return appcopy.TableExists(ctx, c, table)
+}
+
+// Columns lists the columns of every table in the given schemas
+func Columns(ctx context.Context, c Conn, schemas []string) (map[string][]Column, error) {
+	return appcopy.Columns(ctx, c, schemas)
+}
+
+// ForeignKeys lists the foreign keys of tables in the given schemas
+func ForeignKeys(ctx context.Context, c Conn, schemas []string) ([]ForeignKey, error) {
+	return appcopy.ForeignKeys(ctx, c, schemas)
+}
+
+func Truncate(ctx context.Context, c Conn, tables []string) error {
+	return appcopy.Truncate(ctx, c, tables)
+}
+func CompareColumns(src, dst map[string][]Column) []string { return appcopy.CompareColumns(src, dst) }
+func TruncateOrder(tables []string, fks []ForeignKey) []string {
+	return appcopy.TruncateOrder(tables, fks)
+}
+func TruncateBlockers(tables []string, fks []ForeignKey) []ForeignKey {
+	return appcopy.TruncateBlockers(tables, fks)
+}
+
+// Merge upserts rows saved by SaveTable into their tables by primary key
+func Merge(ctx context.Context, c Conn, files map[string]string, deleteMissing bool) ([]MergeResult, error) {
+	return appcopy.Merge(ctx, c, files, deleteMissing)
+}
+
+// MaxValue returns the largest value of a column as text
+func MaxValue(ctx context.Context, c Conn, table, column string) (string, error) {
+	return appcopy.MaxValue(ctx, c, table, column)
+}
+func ChangedSince(column, from, to string) string { return appcopy.ChangedSince(column, from, to) }
+
+// LoadWatermarks reads the incremental sync watermarks stored on the destination
+func LoadWatermarks(ctx context.Context, c Conn, source string) (map[string]string, error) {
+	return appcopy.LoadWatermarks(ctx, c, source)
+}
+
+// StoreWatermarks saves incremental sync watermarks on the destination
+func StoreWatermarks(ctx context.Context, c Conn, source string, marks map[string]string) error {
+	return appcopy.StoreWatermarks(ctx, c, source, marks)
+}
+
+// ReplicationName names the publication, subscription and slot for a destination
+func ReplicationName(dst string) string   { return appcopy.ReplicationName(dst) }
+func SyncSlotPrefix(subOID string) string { return appcopy.SyncSlotPrefix(subOID) }
+
+func TablesWithoutReplicaIdentity(ctx context.Context, c Conn, schemas []string) ([]string, error) {
+	return appcopy.TablesWithoutReplicaIdentity(ctx, c, schemas)
+}
+func CreatePublication(ctx context.Context, c Conn, name string, tables []string) error {
+	return appcopy.CreatePublication(ctx, c, name, tables)
+}
+func DropPublication(ctx context.Context, c Conn, name string) error {
+	return appcopy.DropPublication(ctx, c, name)
+}
+func CreateSubscription(ctx context.Context, c Conn, name, conninfo string) error {
+	return appcopy.CreateSubscription(ctx, c, name, conninfo)
+}
+
+// FindSubscription returns a subscription and its table states, or nil
+func FindSubscription(ctx context.Context, c Conn, name string) (*Subscription, error) {
+	return appcopy.FindSubscription(ctx, c, name)
+}
+
+// DropSubscription removes a subscription without contacting the source
+func DropSubscription(ctx context.Context, c Conn, name string) (string, error) {
+	return appcopy.DropSubscription(ctx, c, name)
+}
+
+// ReplicationSlots lists the logical slots created for this tool's subscriptions
+func ReplicationSlots(ctx context.Context, c Conn) ([]ReplicationSlot, error) {
+	return appcopy.ReplicationSlots(ctx, c)
+}
+
+// DropReplicationSlots drops a subscription's slots on the source
+func DropReplicationSlots(ctx context.Context, c Conn, name, subOID string) ([]string, error) {
+	return appcopy.DropReplicationSlots(ctx, c, name, subOID)
+}
+
+// WipeSchemaNamed drops and recreates one schema, leaving the rest of the database alone
+func WipeSchemaNamed(ctx context.Context, dst Conn, schema string) error {
+	return appcopy.WipeSchemaNamed(ctx, dst, schema)
+}
+func CheckSchemaName(name string) error { return appcopy.CheckSchemaName(name) }
+
+// Schemas lists the user schemas of a database
+func Schemas(ctx context.Context, c Conn) ([]SchemaInfo, error) { return appcopy.Schemas(ctx, c) }
+func DropSchema(ctx context.Context, c Conn, schema string) error {
+	return appcopy.DropSchema(ctx, c, schema)
+}
+
+// RewriteSchema rewrites a plain SQL dump to restore into another schema
+func RewriteSchema(file, from, to string) error { return appcopy.RewriteSchema(file, from, to) }
+
+// MissingRoles returns the roles that don't exist on a database
+func MissingRoles(ctx context.Context, c Conn, roles []string) ([]string, error) {
+	return appcopy.MissingRoles(ctx, c, roles)
+}
+
+// SetOwner hands a schema and the objects in it to a role
+func SetOwner(ctx context.Context, c Conn, schema, owner string) error {
+	return appcopy.SetOwner(ctx, c, schema, owner)
+}
+
+// Grant gives a role privileges on a schema, its tables and sequences, including default privileges
+func Grant(ctx context.Context, c Conn, schema, owner, role string, schemaPrivs, tablePrivs, seqPrivs []string) error {
+	return appcopy.Grant(ctx, c, schema, owner, role, schemaPrivs, tablePrivs, seqPrivs)
+}
+
+// RemapRoles renames roles in the ownership and privilege statements of a dump
+func RemapRoles(file string, roles map[string]string) error { return appcopy.RemapRoles(file, roles) }
+func CheckGrant(schemaPrivs, tablePrivs, seqPrivs []string) error {
+	return appcopy.CheckGrant(schemaPrivs, tablePrivs, seqPrivs)
+}
+
+// DumpGlobals dumps the roles and tablespaces of a server with pg_dumpall --globals-only
+func DumpGlobals(ctx context.Context, c Conn, passwords bool) (string, error) {
+	return appcopy.DumpGlobals(ctx, c, passwords)
+}
+func PlanGlobals(dump string, f GlobalsFilter) GlobalsPlan { return appcopy.PlanGlobals(dump, f) }
+func CheckGlobals(ctx context.Context, c Conn, plan *GlobalsPlan) error {
+	return appcopy.CheckGlobals(ctx, c, plan)
+}
+func ApplyGlobals(ctx context.Context, c Conn, plan GlobalsPlan) error {
+	return appcopy.ApplyGlobals(ctx, c, plan)
+}
+
+// Databases lists the databases on a server, without the templates
+func Databases(ctx context.Context, c Conn) ([]DatabaseInfo, error) { return appcopy.Databases(ctx, c) }
+func CreateDatabase(ctx context.Context, c Conn, name string) error {
+	return appcopy.CreateDatabase(ctx, c, name)
+}
+
+// Fingerprint identifies a database and the shape of its schema, not its rows
+func Fingerprint(ctx context.Context, c Conn) (string, error) { return appcopy.Fingerprint(ctx, c) }
+func DumpArgs(src Conn, outFile string, opts DumpOptions) []string {
+	return appcopy.DumpArgs(src, outFile, opts)
+}
+func WipeArgs(dst Conn, schema string) []string   { return appcopy.WipeArgs(dst, schema) }
+func GlobalsArgs(c Conn, passwords bool) []string { return appcopy.GlobalsArgs(c, passwords) }
+
+// CommandLine renders a command for display, with the password redacted
+func CommandLine(c Conn, program string, args []string, env ...string) string {
+	return appcopy.CommandLine(c, program, args, env...)
+}
+func ImportCommandLine(dst Conn, file string, opts ImportOptions) string {
+	return appcopy.ImportCommandLine(dst, file, opts)
+}
@@ -3,6 +3,8 @@ package ui
 import (
 	"fmt"
 	"os"
+	"strings"
+	"sync"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/pterm/pterm"
@@ -15,6 +17,39 @@ func Select(label string, options []string) (string, error) {
 	return out, err
 }
 
+// SelectTagged is Select with a tag shown next to each option, such as a colored
+// environment label. tag may return "" to leave an option untagged.
+func SelectTagged(label string, options []string, tag func(option string) string) (string, error) {
+	var out string
+	prompt := &survey.Select{
+		Message:     label,
+		Options:     options,
+		Description: func(value string, _ int) string { return tag(value) },
+	}
+	err := survey.AskOne(prompt, &out)
+	return out, err
+}
+
+var colors = map[string]pterm.Color{
+	"red":     pterm.FgRed,
+	"yellow":  pterm.FgYellow,
+	"green":   pterm.FgGreen,
+	"cyan":    pterm.FgCyan,
+	"blue":    pterm.FgBlue,
+	"magenta": pterm.FgMagenta,
+	"white":   pterm.FgWhite,
+	"gray":    pterm.FgGray,
+}
+
+// Colorize renders text in a named color. Unknown or empty colors leave it unchanged.
+func Colorize(color, text string) string {
+	c, ok := colors[strings.ToLower(color)]
+	if !ok {
+		return text
+	}
+	return c.Sprint(text)
+}
+
 func Input(label, def string) (string, error) {
 	var out string
 	prompt := &survey.Input{Message: label, Default: def}
@@ -56,8 +91,69 @@ func ConfirmDanger(msg string) (bool, error) {
 	return ok, err
 }
 
+// ConfirmTyped asks the user to type expected (usually the destination name) to
+// confirm a dangerous action. Anything else counts as a no.
+func ConfirmTyped(msg, expected string) (bool, error) {
+	var out string
+	prompt := &survey.Input{Message: fmt.Sprintf("%s\n  Type %q to confirm:", msg, expected)}
+	if err := survey.AskOne(prompt, &out); err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(out) == expected, nil
+}
+
+// plain is set by Plain.
+var plain bool
+
+// Plain switches to output meant for a log rather than a terminal: no colors, and
+// nothing animated or redrawn in place. Spinners print their title and outcome only.
+func Plain() {
+	plain = true
+	pterm.DisableColor()
+}
+
+// Spinner shows that a step is in progress until it succeeds or fails.
+type Spinner struct {
+	sp *pterm.SpinnerPrinter // nil in plain mode
+}
+
+// StartSpinner starts a Spinner showing text.
+func StartSpinner(text string) *Spinner {
+	if plain {
+		fmt.Println(text)
+		return &Spinner{}
+	}
+	sp, _ := pterm.DefaultSpinner.Start(text)
+	return &Spinner{sp: sp}
+}
+
+// UpdateText changes the text shown, such as to report progress. Plain mode ignores it.
+func (s *Spinner) UpdateText(text string) {
+	if s.sp != nil {
+		s.sp.UpdateText(text)
+	}
+}
+
+// Success stops the spinner with a success message.
+func (s *Spinner) Success(msg string) {
+	if s.sp == nil {
+		pterm.Success.Println(msg)
+		return
+	}
+	s.sp.Success(msg)
+}
+
+// Fail stops the spinner with an error message.
+func (s *Spinner) Fail(msg string) {
+	if s.sp == nil {
+		pterm.Error.Println(msg)
+		return
+	}
+	s.sp.Fail(msg)
+}
+
 func StepSpinner[T any](title string, fn func() (T, error)) (T, error) {
-	spinner, _ := pterm.DefaultSpinner.Start(title)
+	spinner := StartSpinner(title)
 	res, err := fn()
 	if err != nil {
 		spinner.Fail(fmt.Sprintf("%s: %v", title, err))
@@ -83,6 +179,12 @@ func ProgressSteps(steps []string, do func(update func(int))) {
 	pterm.Success.Println("All steps completed successfully!")
 }
 
+// Table renders rows under a header row.
+func Table(header []string, rows [][]string) error {
+	data := append(pterm.TableData{header}, rows...)
+	return pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+}
+
 type Step struct {
 	Title string
 	Run   func() error
@@ -99,3 +201,61 @@ func RunSteps(steps []Step) error {
 	}
 	return nil
 }
+
+// Board shows one status line per name, redrawn in place, for work that runs
+// concurrently. Set may be called from any goroutine. In plain mode it prints a line
+// whenever the first word of a state changes, leaving out progress updates.
+type Board struct {
+	mu     sync.Mutex
+	area   *pterm.AreaPrinter // nil in plain mode
+	names  []string
+	states []string
+	width  int
+}
+
+// StartBoard starts a Board with every name in state.
+func StartBoard(names []string, state string) *Board {
+	b := &Board{names: names, states: make([]string, len(names))}
+	for i, n := range names {
+		b.states[i] = state
+		b.width = max(b.width, len(n))
+	}
+	if plain {
+		return b
+	}
+	b.area, _ = pterm.DefaultArea.Start()
+	b.render()
+	return b
+}
+
+// Set changes the state shown for the i-th name.
+func (b *Board) Set(i int, state string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	prev := b.states[i]
+	b.states[i] = state
+	if b.area == nil {
+		if first, _, _ := strings.Cut(state, " "); !strings.HasPrefix(prev, first) {
+			fmt.Printf("%-*s  %s\n", b.width, b.names[i], state)
+		}
+		return
+	}
+	b.render()
+}
+
+// Stop leaves the last states on screen.
+func (b *Board) Stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.area != nil {
+		b.area.Stop()
+	}
+}
+
+func (b *Board) render() {
+	var sb strings.Builder
+	for i, n := range b.names {
+		fmt.Fprintf(&sb, "%-*s  %s\n", b.width, n, b.states[i])
+	}
+	b.area.Update(sb.String())
+}